@@ -0,0 +1,935 @@
+package tokbox
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// OutputMode is the output mode of an archive
+type OutputMode string
+
+const (
+	// ComposedArchive All streams are recorded to a single file (default option).
+	ComposedArchive OutputMode = "composed"
+	// IndividualArchive Each stream is recorded to its own file.
+	IndividualArchive OutputMode = "individual"
+)
+
+// StreamMode selects how the streams of an archive or broadcast are chosen
+type StreamMode string
+
+const (
+	// AutoStreamMode Every stream of the session is included (default option).
+	AutoStreamMode StreamMode = "auto"
+	// ManualStreamMode Only the streams added with AddStream are included.
+	ManualStreamMode StreamMode = "manual"
+)
+
+// Resolution is the resolution of a composed archive
+type Resolution string
+
+const (
+	// SDLandscape 640x480, the default resolution
+	SDLandscape Resolution = "640x480"
+	// SDPortrait 480x640
+	SDPortrait Resolution = "480x640"
+	// HDLandscape 1280x720
+	HDLandscape Resolution = "1280x720"
+	// HDPortrait 720x1280
+	HDPortrait Resolution = "720x1280"
+	// FHDLandscape 1920x1080
+	FHDLandscape Resolution = "1920x1080"
+	// FHDPortrait 1080x1920
+	FHDPortrait Resolution = "1080x1920"
+)
+
+// Valid reports whether r is one of the resolutions accepted by OpenTok
+func (r Resolution) Valid() bool {
+	switch r {
+	case SDLandscape, SDPortrait, HDLandscape, HDPortrait, FHDLandscape, FHDPortrait:
+		return true
+	}
+	return false
+}
+
+// Portrait reports whether r is taller than it is wide
+func (r Resolution) Portrait() bool {
+	switch r {
+	case SDPortrait, HDPortrait, FHDPortrait:
+		return true
+	}
+	return false
+}
+
+// validateResolution checks that an archive with output mode mode can be
+// recorded at resolution r. All resolutions are valid for composed archives,
+// individual archives keep the resolution of each stream and accept none.
+func validateResolution(r Resolution, mode OutputMode) error {
+	if len(r) == 0 {
+		return nil
+	}
+	if !r.Valid() {
+		return fmt.Errorf("unsupported resolution %q", r)
+	}
+	if mode == IndividualArchive {
+		if r.Portrait() {
+			return fmt.Errorf("portrait resolution %q can not be used with individual output mode", r)
+		}
+		return fmt.Errorf("resolution can not be set with individual output mode")
+	}
+	return nil
+}
+
+// ArchiveOptions holds the settings used to start an archive
+type ArchiveOptions struct {
+	// OutputMode defaults to ComposedArchive when empty
+	OutputMode OutputMode
+	HasAudio   bool
+	HasVideo   bool
+	// Name is shown in the OpenTok dashboard, it is omitted when empty
+	Name string
+	// Resolution only applies to composed archives, the OpenTok default is
+	// used when empty
+	Resolution Resolution
+	// MaxDuration is the maximum duration of the archive in seconds, from
+	// 60 to 172800. 0 uses the OpenTok default of 4 hours.
+	MaxDuration int
+	// HasTranscription requests a transcription of the audio once the
+	// archive is available. Transcription is a paid add-on that must be
+	// enabled for the project, otherwise starting the archive fails with
+	// ErrTranscriptionNotEnabled.
+	HasTranscription bool
+	// TranscriptionLanguage is the primary language of the audio, e.g.
+	// "en-US". The OpenTok default is used when empty.
+	TranscriptionLanguage string
+	// TranscriptionSummary also requests a summary of the transcription
+	TranscriptionSummary bool
+	// StreamMode defaults to AutoStreamMode when empty. With
+	// ManualStreamMode the archive starts without streams, add them with
+	// AddStream.
+	StreamMode StreamMode
+	// Layout is the initial layout of a composed archive, the OpenTok
+	// default is used when nil. It can be changed later with SetLayout.
+	Layout *Layout
+}
+
+// validate checks that the options describe an archive OpenTok can start
+func (opts ArchiveOptions) validate() error {
+	switch opts.OutputMode {
+	case "", ComposedArchive, IndividualArchive:
+	default:
+		return fmt.Errorf("unsupported output mode %q", opts.OutputMode)
+	}
+	if !opts.HasAudio && !opts.HasVideo {
+		return fmt.Errorf("archive requires audio, video or both")
+	}
+	if err := validateStreamMode(opts.StreamMode); err != nil {
+		return err
+	}
+	if !opts.HasTranscription && (len(opts.TranscriptionLanguage) > 0 || opts.TranscriptionSummary) {
+		return fmt.Errorf("transcription settings require HasTranscription")
+	}
+	if opts.HasTranscription && !opts.HasAudio {
+		return fmt.Errorf("transcription requires an archive with audio")
+	}
+	if err := validateResolution(opts.Resolution, opts.OutputMode); err != nil {
+		return err
+	}
+	if opts.Layout != nil {
+		if opts.OutputMode == IndividualArchive {
+			return fmt.Errorf("layout can not be set with individual output mode")
+		}
+		if err := opts.Layout.validate(); err != nil {
+			return err
+		}
+	}
+	return validateMaxDuration("archive", opts.MaxDuration, archiveDurationLimits)
+}
+
+// StartArchivingWithOptions starts archiving the session with opts
+func (s *Session) StartArchivingWithOptions(opts ArchiveOptions, ctx ...context.Context) (*Archive, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	type transcriptionProperties struct {
+		PrimaryLanguageCode string `json:"primaryLanguageCode,omitempty"`
+		HasSummary          bool   `json:"hasSummary,omitempty"`
+	}
+	values := struct {
+		SessionID               string                   `json:"sessionId"`
+		HasAudio                bool                     `json:"hasAudio"`
+		HasVideo                bool                     `json:"hasVideo"`
+		Name                    string                   `json:"name,omitempty"`
+		OutputMode              OutputMode               `json:"outputMode,omitempty"`
+		Resolution              Resolution               `json:"resolution,omitempty"`
+		MaxDuration             int                      `json:"maxDuration,omitempty"`
+		HasTranscription        bool                     `json:"hasTranscription,omitempty"`
+		TranscriptionProperties *transcriptionProperties `json:"transcriptionProperties,omitempty"`
+		StreamMode              StreamMode               `json:"streamMode,omitempty"`
+		Layout                  *Layout                  `json:"layout,omitempty"`
+	}{
+		SessionID:        s.SessionID,
+		HasAudio:         opts.HasAudio,
+		HasVideo:         opts.HasVideo,
+		Name:             opts.Name,
+		OutputMode:       opts.OutputMode,
+		Resolution:       opts.Resolution,
+		MaxDuration:      opts.MaxDuration,
+		HasTranscription: opts.HasTranscription,
+		StreamMode:       opts.StreamMode,
+		Layout:           opts.Layout,
+	}
+	if len(opts.TranscriptionLanguage) > 0 || opts.TranscriptionSummary {
+		values.TranscriptionProperties = &transcriptionProperties{opts.TranscriptionLanguage, opts.TranscriptionSummary}
+	}
+
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	var archive Archive
+	url := s.tokbox.apiPath(apiStartArchivingURL)
+	if err := s.tokbox.doJSON(ctx[0], "POST", url, values, &archive); err != nil {
+		if opts.HasTranscription {
+			err = wrapTranscriptionError(err)
+		}
+		return nil, startArchiveErrors.wrap(wrapInvalidSession(err))
+	}
+
+	archive.S = s
+	return &archive, nil
+}
+
+// ListArchivesFunc lists the archives of the project and calls fn for each
+// archive as soon as it is decoded from the response, instead of holding the
+// whole list in memory. Listing stops at the first error returned by fn.
+// offset and count are ignored when 0, count can be at most 1000.
+// sessionID is ignored when empty.
+// It returns the total number of archives reported by OpenTok.
+// The S field of the archives passed to fn is bound to their session.
+func (t *Tokbox) ListArchivesFunc(offset, count int, sessionID string, fn func(archive *Archive) error, ctx ...context.Context) (int, error) {
+	if offset < 0 {
+		return 0, fmt.Errorf("invalid offset %d", offset)
+	}
+	if count < 0 || count > maxArchivePageSize {
+		return 0, fmt.Errorf("invalid count %d, it must be between 0 and %d", count, maxArchivePageSize)
+	}
+
+	params := url.Values{}
+	if offset > 0 {
+		params.Add("offset", strconv.Itoa(offset))
+	}
+	if count > 0 {
+		params.Add("count", strconv.Itoa(count))
+	}
+	if len(sessionID) > 0 {
+		params.Add("sessionId", sessionID)
+	}
+
+	path := t.apiPath(apiListArchivesURL)
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	res, err := t.do(ctx[0], "GET", path, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// The response is {"count": n, "items": [...]}, walk it token by token so
+	// that items are handed to fn one at a time
+	var total int
+	dec := json.NewDecoder(res.Body)
+	if err = expectDelim(dec, '{'); err != nil {
+		return 0, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return 0, err
+		}
+
+		switch key {
+		case "count":
+			if err = dec.Decode(&total); err != nil {
+				return 0, err
+			}
+		case "items":
+			if err = expectDelim(dec, '['); err != nil {
+				return 0, err
+			}
+			for dec.More() {
+				var archive Archive
+				if err = dec.Decode(&archive); err != nil {
+					return 0, err
+				}
+				archive.S = t.SessionFromID(archive.SessionID)
+				if err = fn(&archive); err != nil {
+					return 0, err
+				}
+			}
+			if err = expectDelim(dec, ']'); err != nil {
+				return 0, err
+			}
+		default:
+			var skip json.RawMessage
+			if err = dec.Decode(&skip); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return total, nil
+}
+
+// ListArchives returns a page of the archives of the project, newest first,
+// and the total number of archives reported by OpenTok. offset and count are
+// ignored when 0, count can be at most 1000. sessionID is ignored when empty.
+// The S field of the returned archives is bound to their session, so e.g.
+// StopArchiving can be called on them.
+func (t *Tokbox) ListArchives(offset, count int, sessionID string, ctx ...context.Context) ([]Archive, int, error) {
+	archives := []Archive{}
+	total, err := t.ListArchivesFunc(offset, count, sessionID, func(archive *Archive) error {
+		archives = append(archives, *archive)
+		return nil
+	}, ctx...)
+	if err != nil {
+		return nil, 0, err
+	}
+	return archives, total, nil
+}
+
+// ListArchives returns a page of the archives of the session like
+// Tokbox.ListArchives, with their S field set to s
+func (s *Session) ListArchives(offset, count int, ctx ...context.Context) ([]Archive, int, error) {
+	archives, total, err := s.tokbox.ListArchives(offset, count, s.SessionID, ctx...)
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := range archives {
+		archives[i].S = s
+	}
+	return archives, total, nil
+}
+
+// CanArchive reports whether an archive can be started for the session and,
+// if not, a human-readable reason. Relayed and end-to-end encrypted sessions
+// can not be archived, and a session can only be recorded by one archive at
+// a time. The media mode is only known for sessions decoded from a create
+// response, so it is not checked for sessions from SessionFromID. OpenTok
+// only exposes whether a project is suspended to account credentials, so
+// that is not checked either.
+// On Google App Engine ctx must be an App Engine context.
+func (s *Session) CanArchive(ctx context.Context) (bool, string, error) {
+	if s.created && s.ResolvedMediaMode() == P2P {
+		return false, "session uses relayed media", nil
+	}
+	if s.IsE2EE() {
+		return false, "session is end-to-end encrypted", nil
+	}
+
+	errRecording := errors.New("recording")
+	_, err := s.tokbox.ListArchivesFunc(0, maxArchivePageSize, s.SessionID, func(archive *Archive) error {
+		if archive.Status == "started" || archive.Status == "paused" {
+			return errRecording
+		}
+		return nil
+	}, ctx)
+	if err == errRecording {
+		return false, "session is already being archived", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	return true, "", nil
+}
+
+// AutoArchives returns the archives of a session created with AlwaysArchive,
+// newest first. Their Name and Resolution reflect the ArchiveName and
+// ArchiveResolution the session was created with.
+func (s *Session) AutoArchives(ctx ...context.Context) ([]Archive, error) {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	archives, err := s.tokbox.listAllArchives(ctx[0], s.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range archives {
+		archives[i].S = s
+	}
+	return archives, nil
+}
+
+// VerifyDownload reads the downloaded archive file from r and reports
+// whether its SHA-256 checksum matches the one OpenTok reported. OpenTok only
+// reports a checksum once the archive is uploaded, so an error is returned
+// when the archive has none.
+func (archive *Archive) VerifyDownload(r io.Reader) (bool, error) {
+	if len(archive.Sha256Sum) == 0 {
+		return false, fmt.Errorf("archive %s has no checksum", archive.ID)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return false, err
+	}
+	return strings.EqualFold(hex.EncodeToString(h.Sum(nil)), archive.Sha256Sum), nil
+}
+
+// Download streams the archive file from its URL to w without buffering it,
+// starting at byte offset. Pass the number of bytes already written by an
+// interrupted download as offset to resume it with an HTTP range request.
+// It returns the number of bytes written to w. The URL is only set once the
+// archive is available, and expires after a few minutes, so fetch the archive
+// again before resuming a download later.
+func (archive *Archive) Download(w io.Writer, offset int64, ctx ...context.Context) (int64, error) {
+	if len(archive.URL) == 0 {
+		return 0, fmt.Errorf("archive %s has no download URL, status is %q", archive.ID, archive.Status)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("invalid offset %d", offset)
+	}
+
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	req, err := newRequest(ctx[0], "GET", archive.URL, nil)
+	if err != nil {
+		return 0, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	res, err := client(ctx[0]).Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusPartialContent:
+	case res.StatusCode == http.StatusOK:
+		// The server ignored the range, skip the bytes already downloaded
+		if offset > 0 {
+			if _, err = io.CopyN(io.Discard, res.Body, offset); err != nil {
+				return 0, err
+			}
+		}
+	default:
+		return 0, newAPIError(res)
+	}
+
+	return io.Copy(w, res.Body)
+}
+
+// maxArchivePageSize is the maximum number of archives OpenTok returns per page
+const maxArchivePageSize = 1000
+
+// ListArchivesBetween lists the archives of the project created in the
+// window [from, to), newest first. A zero from or to leaves that side of the
+// window open. Pages are fetched in parallel, at most SetConcurrency at a
+// time. OpenTok lists archives newest first, so when from is set pages are
+// fetched in batches of SetConcurrency pages and paging stops after the
+// first batch that reaches an archive created before from. Otherwise every
+// page is fetched. Archives returned by more than one page are only listed
+// once. sessionID is ignored when empty.
+func (t *Tokbox) ListArchivesBetween(from, to time.Time, sessionID string, ctx ...context.Context) ([]Archive, error) {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	var all []Archive
+	var err error
+	if from.IsZero() {
+		all, err = t.listAllArchives(ctx[0], sessionID)
+	} else {
+		all, err = t.listArchivesSince(ctx[0], from, sessionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	archives := []Archive{}
+	for _, archive := range all {
+		createdAt := time.UnixMilli(int64(archive.CreatedAt))
+		if !from.IsZero() && createdAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !createdAt.Before(to) {
+			continue
+		}
+		archives = append(archives, archive)
+	}
+	return archives, nil
+}
+
+// listArchivesSince fetches pages of archives, newest first, in batches of
+// SetConcurrency pages fetched in parallel, until a batch reaches an archive
+// created before from
+func (t *Tokbox) listArchivesSince(ctx context.Context, from time.Time, sessionID string) ([]Archive, error) {
+	first, total, err := t.ListArchives(0, maxArchivePageSize, sessionID, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := [][]Archive{first}
+	oldest := first
+	for offset := maxArchivePageSize; offset < total; {
+		if len(oldest) == 0 || time.UnixMilli(int64(oldest[len(oldest)-1].CreatedAt)).Before(from) {
+			break
+		}
+
+		offsets := []int{}
+		for ; offset < total && len(offsets) < t.concurrency; offset += maxArchivePageSize {
+			offsets = append(offsets, offset)
+		}
+		batch, err := t.fetchArchivePages(ctx, sessionID, offsets)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, batch...)
+		oldest = batch[len(batch)-1]
+	}
+	return mergeArchivePages(pages), nil
+}
+
+// ActiveArchives lists the archives of the whole project that are currently
+// recording, i.e. whose status is "started" or "paused", e.g. to stop
+// leaked recordings with StopArchiving. Pages are fetched in parallel like
+// ListArchivesBetween.
+// On Google App Engine ctx must be an App Engine context.
+func (t *Tokbox) ActiveArchives(ctx context.Context) ([]Archive, error) {
+	all, err := t.listAllArchives(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	archives := []Archive{}
+	for _, archive := range all {
+		if archive.Status == "started" || archive.Status == "paused" {
+			archives = append(archives, archive)
+		}
+	}
+	return archives, nil
+}
+
+// listAllArchives fetches every page of archives. The first page tells how
+// many archives there are, the remaining pages are then fetched in parallel
+// and merged in order.
+func (t *Tokbox) listAllArchives(ctx context.Context, sessionID string) ([]Archive, error) {
+	first, total, err := t.ListArchives(0, maxArchivePageSize, sessionID, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets := []int{}
+	for offset := maxArchivePageSize; offset < total; offset += maxArchivePageSize {
+		offsets = append(offsets, offset)
+	}
+	rest, err := t.fetchArchivePages(ctx, sessionID, offsets)
+	if err != nil {
+		return nil, err
+	}
+	return mergeArchivePages(append([][]Archive{first}, rest...)), nil
+}
+
+// fetchArchivePages fetches the pages of archives at offsets in parallel,
+// at most SetConcurrency at a time, and returns them in the same order
+func (t *Tokbox) fetchArchivePages(ctx context.Context, sessionID string, offsets []int) ([][]Archive, error) {
+	pages := make([][]Archive, len(offsets))
+
+	var w sync.WaitGroup
+	var lock sync.Mutex
+	var firstErr error
+	sem := make(chan struct{}, t.concurrency)
+
+	for i, offset := range offsets {
+		w.Add(1)
+		go func(i, offset int) {
+			defer w.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			page, _, err := t.ListArchives(offset, maxArchivePageSize, sessionID, ctx)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			pages[i] = page
+		}(i, offset)
+	}
+
+	w.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return pages, nil
+}
+
+// mergeArchivePages concatenates pages of archives in order. Archives
+// created while listing shift the following pages, so duplicates are
+// dropped.
+func mergeArchivePages(pages [][]Archive) []Archive {
+	seen := map[string]bool{}
+	archives := []Archive{}
+	for _, page := range pages {
+		for _, archive := range page {
+			if seen[archive.ID] {
+				continue
+			}
+			seen[archive.ID] = true
+			archives = append(archives, archive)
+		}
+	}
+	return archives
+}
+
+// WaitForArchive polls the archive every interval until it is available
+// for download, i.e. its status is "available" or "uploaded", and returns
+// it. It fails if the archive status becomes "failed", "deleted" or
+// "expired", or when ctx is done. Each poll runs under ctx, so a slow poll
+// can not outlive the deadline of ctx.
+func (t *Tokbox) WaitForArchive(ctx context.Context, archiveID string, interval time.Duration) (*Archive, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid poll interval %v", interval)
+	}
+
+	for {
+		archive, err := t.pollArchive(ctx, archiveID)
+		if err != nil {
+			return nil, err
+		}
+
+		switch archive.Status {
+		case "available", "uploaded":
+			return archive, nil
+		case "failed", "deleted", "expired":
+			return nil, fmt.Errorf("archive %s is %s", archiveID, archive.Status)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// WaitUntilAvailable waits like WaitForArchive until the archive is
+// available for download, e.g. after StopArchiving, and returns its final
+// state with the URL to download it from
+func (archive *Archive) WaitUntilAvailable(ctx context.Context, interval time.Duration) (*Archive, error) {
+	session, err := archive.session()
+	if err != nil {
+		return nil, err
+	}
+	available, err := session.tokbox.WaitForArchive(ctx, archive.ID, interval)
+	if err != nil {
+		return nil, err
+	}
+	available.S = archive.S
+	return available, nil
+}
+
+// Refresh fetches the archive again and updates it in place, e.g. its
+// Status, Duration, Size and URL. The S field is kept.
+func (archive *Archive) Refresh(ctx ...context.Context) error {
+	session, err := archive.session()
+	if err != nil {
+		return err
+	}
+	latest, err := session.tokbox.GetArchive(archive.ID, ctx...)
+	if err != nil {
+		return err
+	}
+	latest.S = archive.S
+	*archive = *latest
+	return nil
+}
+
+// pollArchive fetches an archive under ctx, so a hanging poll is aborted when
+// ctx is done and ctx.Err() is returned instead of the request error
+func (t *Tokbox) pollArchive(ctx context.Context, archiveID string) (*Archive, error) {
+	var archive Archive
+	url := t.apiPath(apiGetArchiveURL, archiveID)
+	if err := t.doJSON(ctx, "GET", url, nil, &archive); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, getArchiveErrors.wrap(err)
+	}
+	archive.S = t.SessionFromID(archive.SessionID)
+	return &archive, nil
+}
+
+// GetArchive fetches an archive of the project by its ID, e.g. to check its
+// status after a restart. The S field of the returned archive is bound to
+// the session of the archive. If there is no such archive the error wraps
+// ErrArchiveNotFound.
+func (t *Tokbox) GetArchive(archiveID string, ctx ...context.Context) (*Archive, error) {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	var archive Archive
+	url := t.apiPath(apiGetArchiveURL, archiveID)
+	err := t.withRetry(ctx[0], func() error {
+		return t.doJSON(ctx[0], "GET", url, nil, &archive)
+	})
+	if err != nil {
+		return nil, getArchiveErrors.wrap(err)
+	}
+	archive.S = t.SessionFromID(archive.SessionID)
+	return &archive, nil
+}
+
+// GetArchive fetches an archive by its ID and checks that it belongs to the
+// session. An archive of another session is reported as ErrArchiveNotFound,
+// so multi-tenant code can not operate on it by accident.
+func (s *Session) GetArchive(archiveID string, ctx ...context.Context) (*Archive, error) {
+	archive, err := s.tokbox.GetArchive(archiveID, ctx...)
+	if err != nil {
+		return nil, err
+	}
+	if archive.SessionID != s.SessionID {
+		return nil, fmt.Errorf("archive %s belongs to session %s, not %s: %w", archiveID, archive.SessionID, s.SessionID, ErrArchiveNotFound)
+	}
+	archive.S = s
+	return archive, nil
+}
+
+// session returns the session the archive belongs to, or an error if the
+// archive is not bound to a session, e.g. it was built by the caller
+func (archive *Archive) session() (*Session, error) {
+	if archive.S == nil || archive.S.tokbox == nil {
+		return nil, fmt.Errorf("archive %s is not bound to a session", archive.ID)
+	}
+	return archive.S, nil
+}
+
+// Delete permanently deletes the recording of the archive. Only archives
+// whose status is "available" or "uploaded" can be deleted; for any other
+// status an error is returned without calling OpenTok.
+func (archive *Archive) Delete(ctx ...context.Context) error {
+	switch archive.Status {
+	case "available", "uploaded":
+	default:
+		return fmt.Errorf("archive %s can not be deleted while its status is %q", archive.ID, archive.Status)
+	}
+	session, err := archive.session()
+	if err != nil {
+		return err
+	}
+	return session.tokbox.DeleteArchive(archive.ID, ctx...)
+}
+
+// GetTranscription fetches the archive and returns the current state of its
+// transcription. The transcription is requested once the archive is
+// available, poll until its status is "available" to read its URL.
+func (archive *Archive) GetTranscription(ctx ...context.Context) (*ArchiveTranscription, error) {
+	if !archive.HasTranscription {
+		return nil, fmt.Errorf("archive %s was started without transcription", archive.ID)
+	}
+	session, err := archive.session()
+	if err != nil {
+		return nil, err
+	}
+	latest, err := session.tokbox.GetArchive(archive.ID, ctx...)
+	if err != nil {
+		return nil, err
+	}
+	if latest.Transcription == nil {
+		return nil, fmt.Errorf("archive %s has no transcription", archive.ID)
+	}
+	return latest.Transcription, nil
+}
+
+// SetLayout changes the layout of a composed archive while it is recording.
+// layoutType is one of "bestFit", "pip", "verticalPresentation",
+// "horizontalPresentation" or "custom". stylesheet is the CSS of a custom
+// layout and must be empty for the other layouts.
+func (archive *Archive) SetLayout(layoutType string, stylesheet string, ctx ...context.Context) error {
+	layout := Layout{Type: layoutType, Stylesheet: stylesheet}
+	if err := layout.validate(); err != nil {
+		return err
+	}
+
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	session, err := archive.session()
+	if err != nil {
+		return err
+	}
+	t := session.tokbox
+	url := t.apiPath(apiArchiveLayoutURL, archive.ID)
+	return t.doJSON(ctx[0], "PUT", url, layout, nil)
+}
+
+// DeleteArchive permanently deletes the recording of the archive with the
+// given ID. OpenTok rejects the call unless the status of the archive is
+// "available" or "uploaded". If there is no such archive the error wraps
+// ErrArchiveNotFound.
+func (t *Tokbox) DeleteArchive(archiveID string, ctx ...context.Context) error {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	url := t.apiPath(apiDeleteArchiveURL, archiveID)
+	if err := t.doJSON(ctx[0], "DELETE", url, nil, nil); err != nil {
+		return deleteArchiveErrors.wrap(err)
+	}
+	return nil
+}
+
+// ListArchiveStreams returns the streams currently included in an archive
+// that uses the manual stream mode
+func (t *Tokbox) ListArchiveStreams(archiveID string, ctx ...context.Context) ([]IncludedStream, error) {
+	archive, err := t.GetArchive(archiveID, ctx...)
+	if err != nil {
+		return nil, err
+	}
+	return archive.Streams, nil
+}
+
+// AddStream includes a stream in an archive that uses the manual stream
+// mode, recording its audio, its video or both. Calling it again for a stream
+// that is already included updates which of its tracks are recorded, e.g. to
+// pause recording its video. The HasAudio and HasVideo flags of the archive
+// take precedence: a track the archive was started without is never recorded.
+// On success the stream is also updated in Streams.
+func (archive *Archive) AddStream(streamID string, hasAudio, hasVideo bool, ctx ...context.Context) error {
+	if !hasAudio && !hasVideo {
+		return fmt.Errorf("stream requires audio, video or both, use RemoveStream to stop recording it")
+	}
+	if err := archive.patchStreams(streamID, addStreamBody{streamID, hasAudio, hasVideo}, ctx...); err != nil {
+		return err
+	}
+	archive.Streams = setIncludedStream(archive.Streams, IncludedStream{streamID, hasAudio, hasVideo})
+	return nil
+}
+
+// RemoveStream stops recording a stream in an archive that uses the manual
+// stream mode. On success the stream is also removed from Streams.
+func (archive *Archive) RemoveStream(streamID string, ctx ...context.Context) error {
+	if err := archive.patchStreams(streamID, removeStreamBody{streamID}, ctx...); err != nil {
+		return err
+	}
+	archive.Streams = removeIncludedStream(archive.Streams, streamID)
+	return nil
+}
+
+// patchStreams checks the stream mode of the archive and sends values to
+// its streams endpoint
+func (archive *Archive) patchStreams(streamID string, values interface{}, ctx ...context.Context) error {
+	if err := checkManualStreamMode("archive", archive.ID, archive.StreamMode, streamID); err != nil {
+		return err
+	}
+
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	session, err := archive.session()
+	if err != nil {
+		return err
+	}
+	t := session.tokbox
+	url := t.apiPath(apiArchiveStreamsURL, archive.ID)
+	return t.doJSON(ctx[0], "PATCH", url, values, nil)
+}
+
+// addStreamBody is the body of a request including a stream in an archive or broadcast
+type addStreamBody struct {
+	AddStream string `json:"addStream"`
+	HasAudio  bool   `json:"hasAudio"`
+	HasVideo  bool   `json:"hasVideo"`
+}
+
+// removeStreamBody is the body of a request removing a stream from an archive or broadcast
+type removeStreamBody struct {
+	RemoveStream string `json:"removeStream"`
+}
+
+// setIncludedStream adds stream to streams, or replaces the stream with the same ID
+func setIncludedStream(streams []IncludedStream, stream IncludedStream) []IncludedStream {
+	for i := range streams {
+		if streams[i].StreamID == stream.StreamID {
+			streams[i] = stream
+			return streams
+		}
+	}
+	return append(streams, stream)
+}
+
+// removeIncludedStream removes the stream with the given ID from streams
+func removeIncludedStream(streams []IncludedStream, streamID string) []IncludedStream {
+	ret := streams[:0]
+	for _, stream := range streams {
+		if stream.StreamID != streamID {
+			ret = append(ret, stream)
+		}
+	}
+	return ret
+}
+
+// validateStreamMode checks that mode is a stream mode OpenTok accepts
+func validateStreamMode(mode StreamMode) error {
+	switch mode {
+	case "", AutoStreamMode, ManualStreamMode:
+		return nil
+	}
+	return fmt.Errorf("unsupported stream mode %q", mode)
+}
+
+// checkManualStreamMode checks that the streams of the archive or broadcast
+// kind with the given ID can be changed, which only the manual stream mode allows
+func checkManualStreamMode(kind, id string, mode StreamMode, streamID string) error {
+	if mode != ManualStreamMode {
+		return fmt.Errorf("%s %s does not use the manual stream mode, its streams can not be changed", kind, id)
+	}
+	if len(streamID) == 0 {
+		return fmt.Errorf("stream ID must not be empty")
+	}
+	return nil
+}
+
+// expectDelim reads the next JSON token and checks that it is delim
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("unexpected JSON token %v, expected %v", tok, delim)
+	}
+	return nil
+}
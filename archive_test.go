@@ -0,0 +1,964 @@
+package tokbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const archiveListResponse = `{
+	"items": [
+		{"id": "a1", "sessionId": "s1", "status": "available", "extra": {"nested": [1, 2]}},
+		{"id": "a2", "sessionId": "s1", "status": "started"},
+		{"id": "a3", "sessionId": "s1", "status": "stopped"}
+	],
+	"count": 42
+}`
+
+func TestListArchivesFunc(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/project/123456/archive" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("sessionId") != "s1" || r.URL.Query().Get("count") != "3" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(archiveListResponse))
+	})
+
+	var ids []string
+	total, err := tokbox.ListArchivesFunc(0, 3, "s1", func(archive *Archive) error {
+		ids = append(ids, archive.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 42 {
+		t.Fatalf("total = %d, want 42", total)
+	}
+	if len(ids) != 3 || ids[0] != "a1" || ids[2] != "a3" {
+		t.Fatalf("unexpected archives %v", ids)
+	}
+}
+
+func TestListArchivesFuncStops(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(archiveListResponse))
+	})
+
+	stop := errors.New("stop")
+	calls := 0
+	_, err := tokbox.ListArchivesFunc(0, 0, "", func(archive *Archive) error {
+		calls++
+		return stop
+	})
+	if err != stop {
+		t.Fatalf("expected the callback error, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("callback called %d times, want 1", calls)
+	}
+}
+
+func TestListArchiveStreams(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v2/project/123456/archive/a1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"id":"a1","status":"started","streams":[
+			{"streamId":"st1","hasAudio":true,"hasVideo":false},
+			{"streamId":"st2","hasAudio":true,"hasVideo":true}]}`))
+	})
+
+	streams, err := tokbox.ListArchiveStreams("a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 2 {
+		t.Fatalf("got %d streams, want 2", len(streams))
+	}
+	if streams[0] != (IncludedStream{StreamID: "st1", HasAudio: true}) {
+		t.Fatalf("unexpected stream %+v", streams[0])
+	}
+}
+
+func TestValidateResolution(t *testing.T) {
+	for _, r := range []Resolution{SDLandscape, SDPortrait, HDLandscape, HDPortrait, FHDLandscape, FHDPortrait} {
+		if err := validateResolution(r, ComposedArchive); err != nil {
+			t.Errorf("%s: %v", r, err)
+		}
+		if err := validateResolution(r, ""); err != nil {
+			t.Errorf("%s: %v", r, err)
+		}
+		if err := validateResolution(r, IndividualArchive); err == nil {
+			t.Errorf("%s: expected an error with individual output mode", r)
+		}
+	}
+
+	if err := validateResolution("", IndividualArchive); err != nil {
+		t.Error(err)
+	}
+	if err := validateResolution("800x600", ComposedArchive); err == nil {
+		t.Error("expected an error for an unsupported resolution")
+	}
+}
+
+func TestListArchivesBetween(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 2500 archives, one per minute, newest first
+	const total = 2500
+	var requests int32
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+		items := []string{}
+		for i := offset; i < offset+count && i < total; i++ {
+			createdAt := base.Add(time.Duration(total-1-i) * time.Minute).UnixMilli()
+			items = append(items, fmt.Sprintf(`{"id":"a%d","createdAt":%d}`, i, createdAt))
+		}
+		// Simulate an archive shifting into the next page while listing
+		if offset == maxArchivePageSize {
+			items = append([]string{fmt.Sprintf(`{"id":"a%d","createdAt":%d}`, offset-1, base.Add(time.Duration(total-offset)*time.Minute).UnixMilli())}, items...)
+		}
+		fmt.Fprintf(w, `{"count":%d,"items":[%s]}`, total, strings.Join(items, ","))
+	})
+	tokbox.SetConcurrency(2)
+
+	all, err := tokbox.ListArchivesBetween(time.Time{}, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != total {
+		t.Fatalf("got %d archives, want %d", len(all), total)
+	}
+	for i, archive := range all {
+		if archive.ID != fmt.Sprintf("a%d", i) {
+			t.Fatalf("archive %d is %s, order not preserved", i, archive.ID)
+		}
+	}
+
+	from := base.Add(100 * time.Minute)
+	to := base.Add(160 * time.Minute)
+	window, err := tokbox.ListArchivesBetween(from, to, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(window) != 60 {
+		t.Fatalf("got %d archives in the window, want 60", len(window))
+	}
+
+	// A recent window only needs the first page
+	atomic.StoreInt32(&requests, 0)
+	from = base.Add((total - 100) * time.Minute)
+	if window, err = tokbox.ListArchivesBetween(from, time.Time{}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(window) != 100 || atomic.LoadInt32(&requests) != 1 {
+		t.Fatalf("got %d archives after %d requests, want 100 after 1", len(window), atomic.LoadInt32(&requests))
+	}
+}
+
+func TestListArchivesBetweenParallel(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 6000 archives, one per minute, newest first
+	const total = 6000
+	var requests, inFlight, maxInFlight int32
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+		items := []string{}
+		for i := offset; i < offset+count && i < total; i++ {
+			createdAt := base.Add(time.Duration(total-1-i) * time.Minute).UnixMilli()
+			items = append(items, fmt.Sprintf(`{"id":"a%d","createdAt":%d}`, i, createdAt))
+		}
+		fmt.Fprintf(w, `{"count":%d,"items":[%s]}`, total, strings.Join(items, ","))
+	})
+	tokbox.SetConcurrency(2)
+
+	// The window starts in the second page: the first page, then one batch
+	// of two pages
+	from := base.Add((total - 1500) * time.Minute)
+	window, err := tokbox.ListArchivesBetween(from, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(window) != 1500 {
+		t.Fatalf("got %d archives, want 1500", len(window))
+	}
+	for i, archive := range window {
+		if archive.ID != fmt.Sprintf("a%d", i) {
+			t.Fatalf("archive %d is %s, order not preserved", i, archive.ID)
+		}
+	}
+	if got := atomic.LoadInt32(&requests); got != 3 {
+		t.Fatalf("got %d requests, want 3", got)
+	}
+	if got := atomic.LoadInt32(&maxInFlight); got != 2 {
+		t.Fatalf("got at most %d requests in flight, want 2", got)
+	}
+}
+
+func TestVerifyDownload(t *testing.T) {
+	// sha256("archive contents")
+	archive := Archive{ID: "a1", Sha256Sum: "f69f4865f861193a91d1c5544a894167a7137b788d10bac8edbf5d095f45cb4d"}
+
+	ok, err := archive.VerifyDownload(strings.NewReader("archive contents"))
+	if err != nil || !ok {
+		t.Fatalf("expected the checksum to match, got %v, %v", ok, err)
+	}
+	ok, err = archive.VerifyDownload(strings.NewReader("tampered contents"))
+	if err != nil || ok {
+		t.Fatalf("expected the checksum not to match, got %v, %v", ok, err)
+	}
+
+	archive.Sha256Sum = ""
+	if _, err = archive.VerifyDownload(strings.NewReader("archive contents")); err == nil {
+		t.Fatal("expected an error for an archive without checksum")
+	}
+}
+
+func TestCanArchive(t *testing.T) {
+	status := "stopped"
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sessionId") != "s1" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		fmt.Fprintf(w, `{"count":1,"items":[{"id":"a1","sessionId":"s1","status":"%s"}]}`, status)
+	})
+	routed := &Session{SessionID: "s1", MediaServerURL: "https://media.example.com", tokbox: tokbox}
+
+	ok, reason, err := routed.CanArchive(nil)
+	if err != nil || !ok || reason != "" {
+		t.Fatalf("got %v, %q, %v, want true", ok, reason, err)
+	}
+
+	status = "started"
+	ok, reason, err = routed.CanArchive(nil)
+	if err != nil || ok || reason != "session is already being archived" {
+		t.Fatalf("got %v, %q, %v", ok, reason, err)
+	}
+
+	relayed := &Session{SessionID: "s1", tokbox: tokbox, created: true}
+	ok, reason, err = relayed.CanArchive(nil)
+	if err != nil || ok || reason != "session uses relayed media" {
+		t.Fatalf("got %v, %q, %v", ok, reason, err)
+	}
+
+	encrypted := &Session{SessionID: "s1", MediaServerURL: "https://media.example.com", E2EE: true, tokbox: tokbox}
+	ok, reason, err = encrypted.CanArchive(nil)
+	if err != nil || ok || reason != "session is end-to-end encrypted" {
+		t.Fatalf("got %v, %q, %v", ok, reason, err)
+	}
+}
+
+func TestCanArchiveSessionFromID(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"count":0,"items":[]}`)
+	})
+
+	ok, reason, err := tokbox.SessionFromID("s1").CanArchive(nil)
+	if err != nil || !ok || reason != "" {
+		t.Fatalf("got %v, %q, %v, want true", ok, reason, err)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	const contents = "0123456789abcdefghij"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.Header.Get("X-OPENTOK-AUTH")) > 0 {
+			t.Error("the download URL must not receive API credentials")
+		}
+		http.ServeContent(w, r, "archive.mp4", time.Time{}, strings.NewReader(contents))
+	}))
+	defer srv.Close()
+	archive := Archive{ID: "a1", Status: "available", URL: srv.URL + "/archive.mp4"}
+
+	var buf bytes.Buffer
+	n, err := archive.Download(&buf, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(contents)) || buf.String() != contents {
+		t.Fatalf("downloaded %d bytes %q", n, buf.String())
+	}
+
+	// Resume after the first 10 bytes
+	buf.Reset()
+	buf.WriteString(contents[:10])
+	n, err = archive.Download(&buf, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 || buf.String() != contents {
+		t.Fatalf("resumed %d bytes, got %q", n, buf.String())
+	}
+
+	if _, err = (&Archive{ID: "a2", Status: "started"}).Download(&buf, 0); err == nil {
+		t.Fatal("expected an error for an archive without URL")
+	}
+}
+
+func TestWaitForArchive(t *testing.T) {
+	var polls int32
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		status := "stopped"
+		if atomic.AddInt32(&polls, 1) >= 3 {
+			status = "available"
+		}
+		fmt.Fprintf(w, `{"id":"a1","status":"%s","url":"https://example.com/a1.mp4"}`, status)
+	})
+
+	archive, err := tokbox.WaitForArchive(context.Background(), "a1", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive.Status != "available" || polls != 3 {
+		t.Fatalf("got status %s after %d polls", archive.Status, polls)
+	}
+}
+
+func TestWaitForArchiveFailed(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"a1","status":"failed"}`))
+	})
+
+	if _, err := tokbox.WaitForArchive(context.Background(), "a1", time.Millisecond); err == nil {
+		t.Fatal("expected an error for a failed archive")
+	}
+}
+
+func TestWaitForArchiveDeadline(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		// A poll that hangs much longer than the deadline
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := tokbox.WaitForArchive(ctx, "a1", time.Second)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("returned after %v, the deadline was not honored", elapsed)
+	}
+}
+
+func TestAutoArchivesResolution(t *testing.T) {
+	resolution := ""
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/session/create" {
+			body, _ := io.ReadAll(r.Body)
+			params, _ := url.ParseQuery(string(body))
+			resolution = params.Get("archiveResolution")
+			w.Write([]byte(`[{"session_id":"s1","media_server_url":"https://media.example.com"}]`))
+			return
+		}
+		// The archive OpenTok started automatically with the session settings
+		fmt.Fprintf(w, `{"count":1,"items":[{"id":"a1","sessionId":"s1","status":"started","resolution":"%s"}]}`, resolution)
+	})
+
+	opts := SessionOptions{MediaMode: MediaRouter, ArchiveMode: AlwaysArchive, ArchiveResolution: HDPortrait}
+	sessions, err := tokbox.NewSessions(1, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archives, err := sessions[0].AutoArchives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archives) != 1 || archives[0].Resolution != string(HDPortrait) || archives[0].S != sessions[0] {
+		t.Fatalf("unexpected archives %+v", archives)
+	}
+
+	opts.ArchiveMode = ManualArchive
+	if _, err = tokbox.NewSessions(1, opts, nil); err == nil {
+		t.Fatal("expected an error for an archive resolution without AlwaysArchive")
+	}
+	opts = SessionOptions{ArchiveMode: AlwaysArchive, ArchiveResolution: "800x600"}
+	if _, err = tokbox.NewSessions(1, opts, nil); err == nil {
+		t.Fatal("expected an error for an unsupported archive resolution")
+	}
+}
+
+func TestSessionGetArchive(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"a1","sessionId":"s1","status":"available"}`))
+	})
+
+	archive, err := (&Session{SessionID: "s1", tokbox: tokbox}).GetArchive("a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive.ID != "a1" || archive.S == nil {
+		t.Fatalf("unexpected archive %+v", archive)
+	}
+
+	_, err = (&Session{SessionID: "s2", tokbox: tokbox}).GetArchive("a1")
+	if !errors.Is(err, ErrArchiveNotFound) {
+		t.Fatalf("expected ErrArchiveNotFound for another session's archive, got %v", err)
+	}
+}
+
+func TestStopArchivingByID(t *testing.T) {
+	var method, path string
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		w.Write([]byte(`{"id":"a1","sessionId":"s1","status":"stopped","duration":42,"hasAudio":true,"outputMode":"composed"}`))
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	archive, err := session.StopArchivingByID("a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != "POST" || path != "/v2/project/123456/archive/a1/stop" {
+		t.Fatalf("unexpected request %s %s", method, path)
+	}
+	if archive.ID != "a1" || archive.Status != "stopped" || archive.Duration != 42 ||
+		!archive.HasAudio || archive.OutputMode != "composed" || archive.S != session {
+		t.Fatalf("unexpected archive %+v", archive)
+	}
+}
+
+func TestStopArchivingIdempotent(t *testing.T) {
+	status := "stopped"
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			// The first stop succeeded, but its response was lost
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"message":"archive is not started"}`))
+			return
+		}
+		fmt.Fprintf(w, `{"id":"a1","sessionId":"s1","status":"%s"}`, status)
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	archive, err := session.StopArchivingByID("a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive.ID != "a1" || archive.Status != "stopped" {
+		t.Fatalf("unexpected archive %+v", archive)
+	}
+
+	status = "failed"
+	if _, err = session.StopArchivingByID("a1"); !errors.Is(err, ErrArchiveNotStarted) {
+		t.Fatalf("expected ErrArchiveNotStarted for a failed archive, got %v", err)
+	}
+}
+
+func TestGetArchiveNotFound(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/project/123456/archive/a1" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	_, err := tokbox.GetArchive("a1")
+	if !errors.Is(err, ErrArchiveNotFound) {
+		t.Fatalf("expected ErrArchiveNotFound, got %v", err)
+	}
+}
+
+func TestGetArchiveBound(t *testing.T) {
+	var paths []string
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{"id":"a1","sessionId":"s1","status":"started"}`))
+	})
+
+	archive, err := tokbox.GetArchive("a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive.S == nil || archive.S.SessionID != "s1" {
+		t.Fatalf("archive is not bound to its session: %+v", archive)
+	}
+	if _, err = archive.StopArchiving(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(paths, ",") != "GET /v2/project/123456/archive/a1,POST /v2/project/123456/archive/a1/stop" {
+		t.Fatalf("unexpected requests %v", paths)
+	}
+
+	unbound := &Archive{ID: "a2", Status: "available", HasTranscription: true, StreamMode: ManualStreamMode}
+	calls := map[string]func() error{
+		"StopArchiving": func() error { _, err := unbound.StopArchiving(); return err },
+		"Delete":        func() error { return unbound.Delete() },
+		"Refresh":       func() error { return unbound.Refresh() },
+		"SetLayout":     func() error { return unbound.SetLayout("pip", "") },
+		"AddStream":     func() error { return unbound.AddStream("st1", true, true) },
+		"RemoveStream":  func() error { return unbound.RemoveStream("st1") },
+		"GetTranscription": func() error {
+			_, err := unbound.GetTranscription()
+			return err
+		},
+		"WaitUntilAvailable": func() error {
+			_, err := unbound.WaitUntilAvailable(context.Background(), time.Millisecond)
+			return err
+		},
+	}
+	for name, call := range calls {
+		if err := call(); err == nil {
+			t.Errorf("%s: expected an error for an archive without session", name)
+		}
+	}
+}
+
+func TestActiveArchives(t *testing.T) {
+	statuses := []string{"started", "stopped", "paused", "available", "started"}
+	var stopped string
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			stopped = r.URL.Path
+			w.Write([]byte(`{"id":"a0","sessionId":"s0","status":"stopped"}`))
+			return
+		}
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+		items := []string{}
+		for i := offset; i < offset+count && i < len(statuses); i++ {
+			items = append(items, fmt.Sprintf(`{"id":"a%d","sessionId":"s%d","status":"%s"}`, i, i, statuses[i]))
+		}
+		fmt.Fprintf(w, `{"count":%d,"items":[%s]}`, len(statuses), strings.Join(items, ","))
+	})
+
+	archives, err := tokbox.ActiveArchives(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, archive := range archives {
+		ids = append(ids, archive.ID)
+	}
+	if strings.Join(ids, ",") != "a0,a2,a4" {
+		t.Fatalf("unexpected active archives %v", ids)
+	}
+
+	// Listed archives are bound to their session, so they can be stopped
+	if archives[0].S == nil || archives[0].S.SessionID != "s0" {
+		t.Fatalf("archive is not bound to its session: %+v", archives[0])
+	}
+	if _, err = archives[0].StopArchiving(); err != nil {
+		t.Fatal(err)
+	}
+	if stopped != "/v2/project/123456/archive/a0/stop" {
+		t.Fatalf("unexpected stop request %s", stopped)
+	}
+}
+
+func TestListArchives(t *testing.T) {
+	var query url.Values
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`{"count":42,"items":[{"id":"a1","sessionId":"s1"},{"id":"a2","sessionId":"s1"}]}`))
+	})
+
+	archives, total, err := tokbox.ListArchives(10, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 42 || len(archives) != 2 || archives[0].S == nil || archives[0].S.SessionID != "s1" {
+		t.Fatalf("unexpected archives %+v, total %d", archives, total)
+	}
+	if _, ok := query["sessionId"]; ok || query.Get("offset") != "10" || query.Get("count") != "2" {
+		t.Fatalf("unexpected query %v", query)
+	}
+
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+	archives, _, err = session.ListArchives(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query.Get("sessionId") != "s1" || archives[1].S != session {
+		t.Fatalf("unexpected query %v or archives %+v", query, archives)
+	}
+
+	if _, _, err = tokbox.ListArchives(0, maxArchivePageSize+1, ""); err == nil {
+		t.Fatal("expected an error for a count above the API maximum")
+	}
+}
+
+func TestDeleteArchive(t *testing.T) {
+	deleted := 0
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "DELETE" || r.URL.Path != "/v2/project/123456/archive/a1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		deleted++
+		w.WriteHeader(http.StatusNoContent)
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	archive := &Archive{ID: "a1", Status: "available", S: session}
+	if err := archive.Delete(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive.Status = "started"
+	if err := archive.Delete(); err == nil {
+		t.Fatal("expected an error for an archive that is still recording")
+	}
+	if deleted != 1 {
+		t.Fatalf("OpenTok was called %d times, want 1", deleted)
+	}
+
+	if err := tokbox.DeleteArchive("a2"); !errors.Is(err, ErrArchiveNotFound) {
+		t.Fatalf("expected ErrArchiveNotFound, got %v", err)
+	}
+}
+
+func TestStartArchivingWithOptions(t *testing.T) {
+	var body map[string]interface{}
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v2/project/123456/archive" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"id":"a1","sessionId":"s1","status":"started","outputMode":"individual"}`))
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	archive, err := session.StartArchivingWithOptions(ArchiveOptions{OutputMode: IndividualArchive, HasAudio: true, HasVideo: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body["outputMode"] != "individual" || body["sessionId"] != "s1" || body["hasVideo"] != true {
+		t.Fatalf("unexpected body %v", body)
+	}
+	if archive.ID != "a1" || archive.S != session {
+		t.Fatalf("unexpected archive %+v", archive)
+	}
+	if _, ok := body["name"]; ok {
+		t.Fatalf("name must be omitted when empty, got %v", body["name"])
+	}
+
+	if _, err = session.StartArchivingWithOptions(ArchiveOptions{Name: "interview", HasAudio: true}); err != nil {
+		t.Fatal(err)
+	}
+	if body["name"] != "interview" {
+		t.Fatalf("unexpected name %v", body["name"])
+	}
+
+	if _, err = session.StartArchivingWithOptions(ArchiveOptions{HasAudio: true, HasVideo: true, Resolution: HDLandscape}); err != nil {
+		t.Fatal(err)
+	}
+	if body["resolution"] != "1280x720" {
+		t.Fatalf("unexpected resolution %v", body["resolution"])
+	}
+
+	layout := &Layout{Type: "bestFit", ScreenshareType: "verticalPresentation"}
+	if _, err = session.StartArchivingWithOptions(ArchiveOptions{HasAudio: true, HasVideo: true, Layout: layout}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := body["layout"].(map[string]interface{}); got["type"] != "bestFit" || got["screenshareType"] != "verticalPresentation" {
+		t.Fatalf("unexpected layout %v", body["layout"])
+	}
+
+	invalid := []ArchiveOptions{
+		{OutputMode: IndividualArchive, HasAudio: true, Resolution: HDLandscape},
+		{OutputMode: IndividualArchive, HasVideo: true, Layout: &Layout{Type: "pip"}},
+		{HasVideo: true, Layout: &Layout{Type: "custom"}},
+		{HasVideo: true, Resolution: "1280x720p"},
+		{OutputMode: "mixed", HasAudio: true},
+		{OutputMode: ComposedArchive},
+		{HasAudio: true, MaxDuration: 10},
+	}
+	for _, opts := range invalid {
+		if _, err = session.StartArchivingWithOptions(opts); err == nil {
+			t.Fatalf("expected an error for %+v", opts)
+		}
+	}
+}
+
+func TestWaitUntilAvailable(t *testing.T) {
+	var polls int32
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		status := "stopped"
+		if atomic.AddInt32(&polls, 1) >= 2 {
+			status = "uploaded"
+		}
+		fmt.Fprintf(w, `{"id":"a1","status":"%s"}`, status)
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+	stopped := &Archive{ID: "a1", Status: "stopped", S: session}
+
+	archive, err := stopped.WaitUntilAvailable(context.Background(), time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive.Status != "uploaded" || archive.S != session {
+		t.Fatalf("unexpected archive %+v", archive)
+	}
+}
+
+func TestArchiveSetLayout(t *testing.T) {
+	var body map[string]interface{}
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/v2/project/123456/archive/a1/layout" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+	})
+	archive := &Archive{ID: "a1", S: &Session{SessionID: "s1", tokbox: tokbox}}
+
+	if err := archive.SetLayout("custom", "stream.main {width: 100%;}"); err != nil {
+		t.Fatal(err)
+	}
+	if body["type"] != "custom" || body["stylesheet"] != "stream.main {width: 100%;}" {
+		t.Fatalf("unexpected body %v", body)
+	}
+
+	if err := archive.SetLayout("pip", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["stylesheet"]; ok || body["type"] != "pip" {
+		t.Fatalf("unexpected body %v", body)
+	}
+
+	if err := archive.SetLayout("custom", ""); err == nil {
+		t.Fatal("expected an error for a custom layout without stylesheet")
+	}
+	if err := archive.SetLayout("bestFit", "stream {}"); err == nil {
+		t.Fatal("expected an error for a preset layout with a stylesheet")
+	}
+	if err := archive.SetLayout("grid", ""); err == nil {
+		t.Fatal("expected an error for an unknown layout")
+	}
+}
+
+func TestArchiveTranscription(t *testing.T) {
+	var body map[string]interface{}
+	entitled := true
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			w.Write([]byte(`{"id":"a1","sessionId":"s1","status":"available","hasTranscription":true,` +
+				`"transcription":{"status":"available","url":"https://example.com/a1.json","primaryLanguageCode":"en-US"}}`))
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if !entitled {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"code":403,"message":"Transcription is not enabled for this project"}`))
+			return
+		}
+		w.Write([]byte(`{"id":"a1","sessionId":"s1","status":"started","hasTranscription":true,"transcription":{"status":"requested"}}`))
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	opts := ArchiveOptions{HasAudio: true, HasTranscription: true, TranscriptionLanguage: "en-US"}
+	archive, err := session.StartArchivingWithOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	properties, _ := body["transcriptionProperties"].(map[string]interface{})
+	if body["hasTranscription"] != true || properties["primaryLanguageCode"] != "en-US" {
+		t.Fatalf("unexpected body %v", body)
+	}
+	if !archive.HasTranscription || archive.Transcription == nil || archive.Transcription.Status != "requested" {
+		t.Fatalf("unexpected archive %+v", archive)
+	}
+
+	transcription, err := archive.GetTranscription()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transcription.Status != "available" || transcription.URL != "https://example.com/a1.json" {
+		t.Fatalf("unexpected transcription %+v", transcription)
+	}
+
+	entitled = false
+	if _, err = session.StartArchivingWithOptions(opts); !errors.Is(err, ErrTranscriptionNotEnabled) {
+		t.Fatalf("expected ErrTranscriptionNotEnabled, got %v", err)
+	}
+	if errors.Is(err, ErrAuthentication) {
+		t.Fatalf("a 403 for transcription must not match ErrAuthentication: %v", err)
+	}
+
+	invalid := []ArchiveOptions{
+		{HasVideo: true, HasTranscription: true},
+		{HasAudio: true, TranscriptionLanguage: "en-US"},
+	}
+	for _, opts := range invalid {
+		if _, err = session.StartArchivingWithOptions(opts); err == nil {
+			t.Errorf("expected an error for %+v", opts)
+		}
+	}
+	if _, err = (&Archive{ID: "a2", S: session}).GetTranscription(); err == nil {
+		t.Fatal("expected an error for an archive without transcription")
+	}
+}
+
+func TestArchiveUnmarshal(t *testing.T) {
+	data := `{"createdAt":1384221730555,"duration":5049,"hasAudio":true,"hasVideo":true,"id":"a1",` +
+		`"name":"Foo","outputMode":"composed","projectId":123456,"reason":"","resolution":"640x480",` +
+		`"sessionId":"s1","size":247748791,"status":"available","url":"https://example.com/a1.mp4"}`
+	var archive Archive
+	if err := json.Unmarshal([]byte(data), &archive); err != nil {
+		t.Fatal(err)
+	}
+	if archive.Size != 247748791 || archive.Duration != 5049 || archive.ProjectID != 123456 {
+		t.Fatalf("unexpected archive %+v", archive)
+	}
+	if archive.RawExtra != nil {
+		t.Fatalf("unexpected extra fields %v", archive.RawExtra)
+	}
+}
+
+func TestArchiveStreamMode(t *testing.T) {
+	var bodies []map[string]interface{}
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		if r.Method == "POST" {
+			w.Write([]byte(`{"id":"a1","sessionId":"s1","status":"started","streamMode":"manual"}`))
+			return
+		}
+		if r.Method != "PATCH" || r.URL.Path != "/v2/project/123456/archive/a1/streams" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	archive, err := session.StartArchivingWithOptions(ArchiveOptions{HasAudio: true, StreamMode: ManualStreamMode})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bodies[0]["streamMode"] != "manual" || archive.StreamMode != ManualStreamMode {
+		t.Fatalf("unexpected body %v for archive %+v", bodies[0], archive)
+	}
+
+	if err = archive.AddStream("st1", true, false); err != nil {
+		t.Fatal(err)
+	}
+	if bodies[1]["addStream"] != "st1" || bodies[1]["hasAudio"] != true || bodies[1]["hasVideo"] != false {
+		t.Fatalf("unexpected body %v", bodies[1])
+	}
+	if err = archive.RemoveStream("st1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies[2]) != 1 || bodies[2]["removeStream"] != "st1" {
+		t.Fatalf("unexpected body %v", bodies[2])
+	}
+
+	auto := &Archive{ID: "a2", StreamMode: AutoStreamMode, S: session}
+	if err = auto.AddStream("st1", true, true); err == nil {
+		t.Fatal("expected an error for an archive in auto stream mode")
+	}
+	if err = auto.RemoveStream("st1"); err == nil {
+		t.Fatal("expected an error for an archive in auto stream mode")
+	}
+	if err = archive.AddStream("st1", false, false); err == nil {
+		t.Fatal("expected an error for a stream without audio and video")
+	}
+	if _, err = session.StartArchivingWithOptions(ArchiveOptions{HasAudio: true, StreamMode: "spotlight"}); err == nil {
+		t.Fatal("expected an error for an unknown stream mode")
+	}
+	if len(bodies) != 3 {
+		t.Fatalf("unexpected requests %v", bodies)
+	}
+}
+
+func TestArchiveRefresh(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/project/123456/archive/a1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"id":"a1","sessionId":"s1","status":"available","duration":62,"size":1024,"url":"https://example.com/a1.mp4"}`))
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+	archive := &Archive{ID: "a1", SessionID: "s1", Status: "started", S: session}
+
+	if err := archive.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if archive.Status != "available" || archive.Duration != 62 || archive.Size != 1024 ||
+		archive.URL != "https://example.com/a1.mp4" || archive.S != session {
+		t.Fatalf("unexpected archive %+v", archive)
+	}
+
+	missing := &Archive{ID: "a2", Status: "started", S: session}
+	if err := missing.Refresh(); !errors.Is(err, ErrArchiveNotFound) {
+		t.Fatalf("expected ErrArchiveNotFound, got %v", err)
+	}
+	if missing.Status != "started" {
+		t.Fatalf("archive was changed by a failed refresh: %+v", missing)
+	}
+}
+
+func TestArchiveToggleStreamTracks(t *testing.T) {
+	var bodies []map[string]interface{}
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	archive := &Archive{ID: "a1", StreamMode: ManualStreamMode, S: &Session{SessionID: "s1", tokbox: tokbox},
+		Streams: []IncludedStream{{StreamID: "st1", HasAudio: true, HasVideo: true}, {StreamID: "st2", HasAudio: true}}}
+
+	// Pause the video of st1, then resume it
+	if err := archive.AddStream("st1", true, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Streams) != 2 || archive.Streams[0] != (IncludedStream{StreamID: "st1", HasAudio: true}) {
+		t.Fatalf("unexpected streams %+v", archive.Streams)
+	}
+	if err := archive.AddStream("st1", true, true); err != nil {
+		t.Fatal(err)
+	}
+	if bodies[1]["addStream"] != "st1" || bodies[1]["hasVideo"] != true || !archive.Streams[0].HasVideo {
+		t.Fatalf("unexpected body %v for streams %+v", bodies[1], archive.Streams)
+	}
+
+	if err := archive.RemoveStream("st2"); err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Streams) != 1 || archive.Streams[0].StreamID != "st1" {
+		t.Fatalf("unexpected streams %+v", archive.Streams)
+	}
+	if err := archive.AddStream("st3", false, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Streams) != 2 || archive.Streams[1] != (IncludedStream{StreamID: "st3", HasVideo: true}) {
+		t.Fatalf("unexpected streams %+v", archive.Streams)
+	}
+}
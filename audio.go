@@ -0,0 +1,85 @@
+package tokbox
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Sample rates supported by the Audio Connector, in Hz
+const (
+	AudioRate8kHz  = 8000
+	AudioRate16kHz = 16000
+)
+
+// AudioConnectorOptions holds the settings of an Audio Connector WebSocket
+type AudioConnectorOptions struct {
+	// Token is a token of the session the Audio Connector connects with
+	Token string
+	// Streams are the IDs of the streams sent to the WebSocket. Every stream
+	// of the session is sent when empty.
+	Streams []string
+	// Headers are sent with the initial WebSocket message
+	Headers map[string]string
+	// AudioRate is the sample rate of the audio sent to the WebSocket, 8000
+	// or 16000. 0 uses the OpenTok default of 16000.
+	AudioRate int
+}
+
+// AudioConnection is an Audio Connector streaming the audio of a session to a WebSocket
+type AudioConnection struct {
+	ID           string `json:"id"`
+	ConnectionID string `json:"connectionId"`
+}
+
+// validate checks the WebSocket URI and the audio rate
+func (opts AudioConnectorOptions) validate(websocketURI string) error {
+	if !strings.HasPrefix(websocketURI, "ws://") && !strings.HasPrefix(websocketURI, "wss://") {
+		return fmt.Errorf("invalid WebSocket URI %q, it must start with ws:// or wss://", websocketURI)
+	}
+	if len(opts.Token) == 0 {
+		return fmt.Errorf("token must not be empty")
+	}
+	switch opts.AudioRate {
+	case 0, AudioRate8kHz, AudioRate16kHz:
+	default:
+		return fmt.Errorf("unsupported audio rate %d, it must be %d or %d", opts.AudioRate, AudioRate8kHz, AudioRate16kHz)
+	}
+	return nil
+}
+
+// ConnectAudio starts an Audio Connector that streams the audio of the
+// session to a WebSocket server, e.g. for transcription
+func (s *Session) ConnectAudio(websocketURI string, opts AudioConnectorOptions, ctx ...context.Context) (*AudioConnection, error) {
+	if err := opts.validate(websocketURI); err != nil {
+		return nil, err
+	}
+
+	type websocket struct {
+		URI       string            `json:"uri"`
+		Streams   []string          `json:"streams,omitempty"`
+		Headers   map[string]string `json:"headers,omitempty"`
+		AudioRate int               `json:"audioRate,omitempty"`
+	}
+	values := struct {
+		SessionID string    `json:"sessionId"`
+		Token     string    `json:"token"`
+		WebSocket websocket `json:"websocket"`
+	}{
+		SessionID: s.SessionID,
+		Token:     opts.Token,
+		WebSocket: websocket{websocketURI, opts.Streams, opts.Headers, opts.AudioRate},
+	}
+
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	var connection AudioConnection
+	url := s.tokbox.apiPath(apiConnectAudioURL)
+	if err := s.tokbox.doJSON(ctx[0], "POST", url, values, &connection); err != nil {
+		return nil, err
+	}
+	return &connection, nil
+}
@@ -0,0 +1,51 @@
+package tokbox
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestConnectAudio(t *testing.T) {
+	var path string
+	var body map[string]interface{}
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"id":"ac1","connectionId":"c1"}`))
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	opts := AudioConnectorOptions{
+		Token:     "T1==abc",
+		Streams:   []string{"st1"},
+		Headers:   map[string]string{"language": "en"},
+		AudioRate: AudioRate8kHz,
+	}
+	connection, err := session.ConnectAudio("wss://transcribe.example.com/ws", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connection.ID != "ac1" || connection.ConnectionID != "c1" {
+		t.Fatalf("unexpected connection %+v", connection)
+	}
+	websocket, _ := body["websocket"].(map[string]interface{})
+	if path != "/v2/project/123456/connect" || body["sessionId"] != "s1" || body["token"] != "T1==abc" ||
+		websocket["uri"] != "wss://transcribe.example.com/ws" || websocket["audioRate"] != float64(8000) {
+		t.Fatalf("unexpected request %s %v", path, body)
+	}
+
+	invalid := []struct {
+		uri  string
+		opts AudioConnectorOptions
+	}{
+		{"https://transcribe.example.com/ws", AudioConnectorOptions{Token: "T1==abc"}},
+		{"wss://transcribe.example.com/ws", AudioConnectorOptions{}},
+		{"wss://transcribe.example.com/ws", AudioConnectorOptions{Token: "T1==abc", AudioRate: 44100}},
+	}
+	for _, c := range invalid {
+		if _, err := session.ConnectAudio(c.uri, c.opts); err == nil {
+			t.Errorf("expected an error for %s %+v", c.uri, c.opts)
+		}
+	}
+}
@@ -0,0 +1,464 @@
+package tokbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"golang.org/x/net/context"
+)
+
+// BroadcastStatus is the status of a broadcast
+type BroadcastStatus string
+
+const (
+	// BroadcastStarted The broadcast is live.
+	BroadcastStarted BroadcastStatus = "started"
+	// BroadcastStopped The broadcast has been stopped.
+	BroadcastStopped BroadcastStatus = "stopped"
+	// BroadcastFailed The broadcast could not be streamed.
+	BroadcastFailed BroadcastStatus = "failed"
+)
+
+// Layout is the layout of a composed archive or broadcast
+type Layout struct {
+	Type            string `json:"type"`
+	Stylesheet      string `json:"stylesheet,omitempty"`
+	ScreenshareType string `json:"screenshareType,omitempty"`
+}
+
+// validate checks that the layout type is known, that a stylesheet is set
+// for custom layouts only and that a screenshare layout is only set with
+// bestFit
+func (l Layout) validate() error {
+	switch l.Type {
+	case "bestFit", "pip", "verticalPresentation", "horizontalPresentation":
+		if len(l.Stylesheet) > 0 {
+			return fmt.Errorf("layout %s does not accept a stylesheet", l.Type)
+		}
+	case "custom":
+		if len(l.Stylesheet) == 0 {
+			return fmt.Errorf("custom layout requires a stylesheet")
+		}
+	default:
+		return fmt.Errorf("unsupported layout type %q", l.Type)
+	}
+
+	switch l.ScreenshareType {
+	case "":
+	case "bestFit", "pip", "verticalPresentation", "horizontalPresentation":
+		if l.Type != "bestFit" {
+			return fmt.Errorf("screenshare layout requires the bestFit layout, not %s", l.Type)
+		}
+	default:
+		return fmt.Errorf("unsupported screenshare layout type %q", l.ScreenshareType)
+	}
+	return nil
+}
+
+// HLSOptions enables the HLS output of a broadcast
+type HLSOptions struct {
+	// DVR lets viewers pause and seek back in players that support it. It
+	// can not be combined with LowLatency.
+	DVR        bool `json:"dvr,omitempty"`
+	LowLatency bool `json:"lowLatency,omitempty"`
+}
+
+// RTMPTarget is an RTMP server a broadcast is streamed to
+type RTMPTarget struct {
+	ID         string `json:"id,omitempty"`
+	ServerURL  string `json:"serverUrl"`
+	StreamName string `json:"streamName"`
+	Status     string `json:"status,omitempty"` // Set by OpenTok in responses
+}
+
+// maxRTMPTargets is the maximum number of RTMP targets of a broadcast
+const maxRTMPTargets = 5
+
+// validateRTMPTargets checks that there are at most 5 targets, that they
+// have unique IDs when there are several and that they point to RTMP servers
+func validateRTMPTargets(targets []RTMPTarget) error {
+	if len(targets) > maxRTMPTargets {
+		return fmt.Errorf("broadcast supports at most %d RTMP targets, got %d", maxRTMPTargets, len(targets))
+	}
+	ids := map[string]bool{}
+	for _, target := range targets {
+		if len(targets) > 1 {
+			if len(target.ID) == 0 {
+				return fmt.Errorf("RTMP target %s requires an ID when there are several targets", target.ServerURL)
+			}
+			if ids[target.ID] {
+				return fmt.Errorf("duplicate RTMP target ID %q", target.ID)
+			}
+			ids[target.ID] = true
+		}
+		u, err := url.Parse(target.ServerURL)
+		if err != nil || (u.Scheme != "rtmp" && u.Scheme != "rtmps") || len(u.Host) == 0 {
+			return fmt.Errorf("invalid RTMP server URL %q, it must be an rtmp:// or rtmps:// URL", target.ServerURL)
+		}
+	}
+	return nil
+}
+
+// BroadcastOptions holds the settings used to start a broadcast.
+// At least one output (HLS or RTMP) is required.
+type BroadcastOptions struct {
+	HLS *HLSOptions
+	// RTMP holds up to 5 targets. Each target needs a unique ID when there
+	// are several of them.
+	RTMP []RTMPTarget
+	// AudioOnly broadcasts the audio of the session without video. Audio-only
+	// broadcasts have no layout, so Layout must be nil.
+	AudioOnly bool
+	Layout    *Layout
+	// MaxDuration is the maximum duration of the broadcast in seconds, from
+	// 60 to 36000. 0 uses the OpenTok default of 4 hours.
+	MaxDuration int
+	// Resolution of the broadcast, the OpenTok default is used when empty
+	Resolution Resolution
+	// StreamMode defaults to AutoStreamMode when empty. With
+	// ManualStreamMode the broadcast starts without streams, add them with
+	// AddStream.
+	StreamMode StreamMode
+}
+
+// BroadcastURLs holds where a broadcast can be watched
+type BroadcastURLs struct {
+	HLS  string       `json:"hls"`
+	RTMP []RTMPTarget `json:"rtmp"`
+}
+
+// BroadcastSettings holds the output settings OpenTok applied to a broadcast
+type BroadcastSettings struct {
+	HLS HLSOptions `json:"hls"`
+}
+
+// Broadcast struct represents broadcast create response
+type Broadcast struct {
+	ID            string            `json:"id"`
+	SessionID     string            `json:"sessionId"`
+	ProjectID     int               `json:"projectId"`
+	CreatedAt     int               `json:"createdAt"`
+	UpdatedAt     int               `json:"updatedAt"`
+	Resolution    string            `json:"resolution"`
+	Status        BroadcastStatus   `json:"status"`
+	HasAudio      bool              `json:"hasAudio"`
+	HasVideo      bool              `json:"hasVideo"`
+	BroadcastURLs BroadcastURLs     `json:"broadcastUrls"`
+	Settings      BroadcastSettings `json:"settings"`
+	Streams       []IncludedStream  `json:"streams"`
+	StreamMode    StreamMode        `json:"streamMode"`
+	// RawExtra holds the keys of the response this package does not know yet
+	RawExtra map[string]json.RawMessage `json:"-"`
+	S        *Session                   `json:"-"`
+}
+
+// AudioOnly reports whether the broadcast streams audio without video
+func (b *Broadcast) AudioOnly() bool {
+	return b.HasAudio && !b.HasVideo
+}
+
+// DVRURL returns the HLS playlist URL when the broadcast has DVR enabled, so
+// viewers can seek back in it
+func (b *Broadcast) DVRURL() (string, bool) {
+	if !b.Settings.HLS.DVR || len(b.BroadcastURLs.HLS) == 0 {
+		return "", false
+	}
+	return b.BroadcastURLs.HLS, true
+}
+
+// FilterBroadcastsByStatus returns the broadcasts of bs whose status is one
+// of status, in their original order
+func FilterBroadcastsByStatus(bs []Broadcast, status ...BroadcastStatus) []Broadcast {
+	ret := []Broadcast{}
+	for _, b := range bs {
+		for _, st := range status {
+			if b.Status == st {
+				ret = append(ret, b)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// validate checks that the options describe a broadcast OpenTok can start
+func (opts BroadcastOptions) validate() error {
+	if opts.HLS == nil && len(opts.RTMP) == 0 {
+		return fmt.Errorf("broadcast requires at least one HLS or RTMP output")
+	}
+	if err := validateRTMPTargets(opts.RTMP); err != nil {
+		return err
+	}
+	if opts.HLS != nil && opts.HLS.DVR && opts.HLS.LowLatency {
+		return fmt.Errorf("HLS DVR and low latency are mutually exclusive")
+	}
+	if opts.AudioOnly && opts.Layout != nil {
+		return fmt.Errorf("audio-only broadcasts do not support a layout")
+	}
+	if len(opts.Resolution) > 0 && !opts.Resolution.Valid() {
+		return fmt.Errorf("unsupported resolution %q", opts.Resolution)
+	}
+	if err := validateStreamMode(opts.StreamMode); err != nil {
+		return err
+	}
+	return validateMaxDuration("broadcast", opts.MaxDuration, broadcastDurationLimits)
+}
+
+// StartBroadcast starts broadcasting the session to HLS and/or RTMP outputs,
+// e.g. to stream it live to YouTube over RTMP. The returned broadcast holds
+// the HLS playback URL and the status of each RTMP target.
+func (s *Session) StartBroadcast(opts BroadcastOptions, ctx ...context.Context) (*Broadcast, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	type outputs struct {
+		HLS  *HLSOptions  `json:"hls,omitempty"`
+		RTMP []RTMPTarget `json:"rtmp,omitempty"`
+	}
+	values := struct {
+		SessionID   string     `json:"sessionId"`
+		Layout      *Layout    `json:"layout,omitempty"`
+		Outputs     outputs    `json:"outputs"`
+		HasAudio    bool       `json:"hasAudio"`
+		HasVideo    bool       `json:"hasVideo"`
+		MaxDuration int        `json:"maxDuration,omitempty"`
+		Resolution  Resolution `json:"resolution,omitempty"`
+		StreamMode  StreamMode `json:"streamMode,omitempty"`
+	}{
+		SessionID:   s.SessionID,
+		Layout:      opts.Layout,
+		Outputs:     outputs{opts.HLS, opts.RTMP},
+		HasAudio:    true,
+		HasVideo:    !opts.AudioOnly,
+		MaxDuration: opts.MaxDuration,
+		Resolution:  opts.Resolution,
+		StreamMode:  opts.StreamMode,
+	}
+
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	var broadcast Broadcast
+	url := s.tokbox.apiPath(apiStartBroadcastURL)
+	if err := s.tokbox.doJSON(ctx[0], "POST", url, values, &broadcast); err != nil {
+		return nil, wrapInvalidSession(err)
+	}
+
+	broadcast.S = s
+	return &broadcast, nil
+}
+
+// Stop stops the broadcast and returns its final state
+func (b *Broadcast) Stop(ctx ...context.Context) (*Broadcast, error) {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	t, err := b.client()
+	if err != nil {
+		return nil, err
+	}
+	stopped, err := t.stopBroadcast(ctx[0], b.ID)
+	if err != nil {
+		return nil, err
+	}
+	stopped.S = b.S
+	return stopped, nil
+}
+
+// client returns the Tokbox the broadcast was fetched with, or an error if
+// the broadcast is not bound to a session, e.g. it was built by the caller
+func (b *Broadcast) client() (*Tokbox, error) {
+	if b.S == nil || b.S.tokbox == nil {
+		return nil, fmt.Errorf("broadcast %s is not bound to a session", b.ID)
+	}
+	return b.S.tokbox, nil
+}
+
+// AddStream includes a stream in a broadcast that uses the manual stream
+// mode, e.g. to only broadcast the active speaker. Like Archive.AddStream it
+// also updates the tracks of a stream that is already included.
+// On success the stream is also updated in Streams.
+func (b *Broadcast) AddStream(streamID string, hasAudio, hasVideo bool, ctx ...context.Context) error {
+	if !hasAudio && !hasVideo {
+		return fmt.Errorf("stream requires audio, video or both, use RemoveStream to stop broadcasting it")
+	}
+	if err := b.patchStreams(streamID, addStreamBody{streamID, hasAudio, hasVideo}, ctx...); err != nil {
+		return err
+	}
+	b.Streams = setIncludedStream(b.Streams, IncludedStream{streamID, hasAudio, hasVideo})
+	return nil
+}
+
+// RemoveStream removes a stream from a broadcast that uses the manual stream
+// mode. On success the stream is also removed from Streams.
+func (b *Broadcast) RemoveStream(streamID string, ctx ...context.Context) error {
+	if err := b.patchStreams(streamID, removeStreamBody{streamID}, ctx...); err != nil {
+		return err
+	}
+	b.Streams = removeIncludedStream(b.Streams, streamID)
+	return nil
+}
+
+// patchStreams checks the stream mode of the broadcast and sends values to
+// its streams endpoint
+func (b *Broadcast) patchStreams(streamID string, values interface{}, ctx ...context.Context) error {
+	if err := checkManualStreamMode("broadcast", b.ID, b.StreamMode, streamID); err != nil {
+		return err
+	}
+
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	t, err := b.client()
+	if err != nil {
+		return err
+	}
+	url := t.apiPath(apiBroadcastStreamsURL, b.ID)
+	return t.doJSON(ctx[0], "PATCH", url, values, nil)
+}
+
+// SetLayout changes the layout of a broadcast while it is running.
+// layoutType and stylesheet are checked like with Archive.SetLayout.
+func (b *Broadcast) SetLayout(layoutType string, stylesheet string, ctx ...context.Context) error {
+	return b.SetLayoutWithScreenshare(layoutType, stylesheet, "", ctx...)
+}
+
+// SetLayoutWithScreenshare changes the layout of a broadcast like SetLayout,
+// and sets the layout used while a stream is sharing a screen.
+// screenshareType is one of the preset layouts and requires the bestFit
+// layout, it is ignored when empty.
+func (b *Broadcast) SetLayoutWithScreenshare(layoutType, stylesheet, screenshareType string, ctx ...context.Context) error {
+	layout := Layout{Type: layoutType, Stylesheet: stylesheet, ScreenshareType: screenshareType}
+	if err := layout.validate(); err != nil {
+		return err
+	}
+
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	t, err := b.client()
+	if err != nil {
+		return err
+	}
+	url := t.apiPath(apiBroadcastLayoutURL, b.ID)
+	return t.doJSON(ctx[0], "PUT", url, layout, nil)
+}
+
+// StopBroadcast stops the broadcast with the given ID and returns its final state
+func (t *Tokbox) StopBroadcast(broadcastID string, ctx ...context.Context) (*Broadcast, error) {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	stopped, err := t.stopBroadcast(ctx[0], broadcastID)
+	if err != nil {
+		return nil, err
+	}
+	stopped.S = t.SessionFromID(stopped.SessionID)
+	return stopped, nil
+}
+
+// stopBroadcast stops the broadcast with the given ID
+func (t *Tokbox) stopBroadcast(ctx context.Context, broadcastID string) (*Broadcast, error) {
+	var broadcast Broadcast
+	url := t.apiPath(apiStopBroadcastURL, broadcastID)
+	if err := t.doJSON(ctx, "POST", url, nil, &broadcast); err != nil {
+		return nil, err
+	}
+	return &broadcast, nil
+}
+
+// maxBroadcastPageSize is the maximum number of broadcasts OpenTok returns per page
+const maxBroadcastPageSize = 1000
+
+// ListBroadcasts returns a page of the broadcasts of the project, newest
+// first, and the total number of broadcasts reported by OpenTok. offset and
+// count are ignored when 0, count can be at most 1000. sessionID is ignored
+// when empty.
+func (t *Tokbox) ListBroadcasts(offset, count int, sessionID string, ctx ...context.Context) ([]Broadcast, int, error) {
+	if offset < 0 {
+		return nil, 0, fmt.Errorf("invalid offset %d", offset)
+	}
+	if count < 0 || count > maxBroadcastPageSize {
+		return nil, 0, fmt.Errorf("invalid count %d, it must be between 0 and %d", count, maxBroadcastPageSize)
+	}
+
+	params := url.Values{}
+	if offset > 0 {
+		params.Add("offset", strconv.Itoa(offset))
+	}
+	if count > 0 {
+		params.Add("count", strconv.Itoa(count))
+	}
+	if len(sessionID) > 0 {
+		params.Add("sessionId", sessionID)
+	}
+
+	path := t.apiPath(apiListBroadcastsURL)
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	var response struct {
+		Count int         `json:"count"`
+		Items []Broadcast `json:"items"`
+	}
+	if err := t.doJSON(ctx[0], "GET", path, nil, &response); err != nil {
+		return nil, 0, err
+	}
+	if response.Items == nil {
+		response.Items = []Broadcast{}
+	}
+	for i := range response.Items {
+		response.Items[i].S = t.SessionFromID(response.Items[i].SessionID)
+	}
+	return response.Items, response.Count, nil
+}
+
+// listAllBroadcasts fetches every page of broadcasts. Broadcasts created
+// while listing shift the following pages, so duplicates are dropped.
+func (t *Tokbox) listAllBroadcasts(ctx context.Context, sessionID string) ([]Broadcast, error) {
+	seen := map[string]bool{}
+	broadcasts := []Broadcast{}
+	for offset := 0; ; offset += maxBroadcastPageSize {
+		page, total, err := t.ListBroadcasts(offset, maxBroadcastPageSize, sessionID, ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, broadcast := range page {
+			if !seen[broadcast.ID] {
+				seen[broadcast.ID] = true
+				broadcasts = append(broadcasts, broadcast)
+			}
+		}
+		if len(page) == 0 || offset+maxBroadcastPageSize >= total {
+			return broadcasts, nil
+		}
+	}
+}
+
+// ListBroadcastStreams returns the streams currently included in a broadcast
+// that uses the manual stream mode
+func (t *Tokbox) ListBroadcastStreams(broadcastID string, ctx ...context.Context) ([]IncludedStream, error) {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	var broadcast Broadcast
+	url := t.apiPath(apiGetBroadcastURL, broadcastID)
+	if err := t.doJSON(ctx[0], "GET", url, nil, &broadcast); err != nil {
+		return nil, err
+	}
+	return broadcast.Streams, nil
+}
@@ -0,0 +1,395 @@
+package tokbox
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestStartBroadcastAudioOnly(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v2/project/123456/broadcast" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["hasVideo"] != false || body["hasAudio"] != true {
+			t.Errorf("unexpected body %v", body)
+		}
+		if _, ok := body["layout"]; ok {
+			t.Errorf("layout must be omitted, got %v", body["layout"])
+		}
+		w.Write([]byte(`{"id":"b1","sessionId":"s1","status":"started","hasAudio":true,"hasVideo":false,
+			"broadcastUrls":{"hls":"https://example.com/b1.m3u8"}}`))
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	broadcast, err := session.StartBroadcast(BroadcastOptions{HLS: &HLSOptions{}, AudioOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !broadcast.AudioOnly() {
+		t.Fatal("expected an audio-only broadcast")
+	}
+	if broadcast.BroadcastURLs.HLS != "https://example.com/b1.m3u8" || broadcast.S != session {
+		t.Fatalf("unexpected broadcast %+v", broadcast)
+	}
+}
+
+func TestStartBroadcastRTMP(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Resolution string `json:"resolution"`
+			Outputs    struct {
+				RTMP []map[string]string `json:"rtmp"`
+			} `json:"outputs"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Resolution != "1280x720" || len(body.Outputs.RTMP) != 1 {
+			t.Errorf("unexpected body %+v", body)
+		} else if target := body.Outputs.RTMP[0]; target["id"] != "yt" || target["serverUrl"] != "rtmp://a.rtmp.youtube.com/live2" || target["streamName"] != "key" {
+			t.Errorf("unexpected RTMP target %v", target)
+		}
+		w.Write([]byte(`{"id":"b1","sessionId":"s1","createdAt":1700000000000,"status":"started",
+			"broadcastUrls":{"rtmp":[{"id":"yt","serverUrl":"rtmp://a.rtmp.youtube.com/live2","streamName":"key","status":"connecting"}]}}`))
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	opts := BroadcastOptions{
+		RTMP:       []RTMPTarget{{ID: "yt", ServerURL: "rtmp://a.rtmp.youtube.com/live2", StreamName: "key"}},
+		Resolution: HDLandscape,
+	}
+	broadcast, err := session.StartBroadcast(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if broadcast.ID != "b1" || broadcast.CreatedAt != 1700000000000 || len(broadcast.BroadcastURLs.RTMP) != 1 || broadcast.BroadcastURLs.RTMP[0].Status != "connecting" {
+		t.Fatalf("unexpected broadcast %+v", broadcast)
+	}
+
+	opts.Resolution = "720p"
+	if _, err = session.StartBroadcast(opts); err == nil {
+		t.Fatal("expected an error for an unsupported resolution")
+	}
+}
+
+func TestStartBroadcastValidation(t *testing.T) {
+	session := &Session{SessionID: "s1", tokbox: New("123456", "secret")}
+
+	if _, err := session.StartBroadcast(BroadcastOptions{AudioOnly: true}); err == nil {
+		t.Fatal("expected an error for a broadcast without outputs")
+	}
+
+	opts := BroadcastOptions{HLS: &HLSOptions{}, AudioOnly: true, Layout: &Layout{Type: "bestFit"}}
+	if _, err := session.StartBroadcast(opts); err == nil {
+		t.Fatal("expected an error for an audio-only broadcast with a layout")
+	}
+
+	target := func(id string) RTMPTarget {
+		return RTMPTarget{ID: id, ServerURL: "rtmps://live.example.com/app", StreamName: "key"}
+	}
+	invalid := [][]RTMPTarget{
+		{target("a"), target("b"), target("c"), target("d"), target("e"), target("f")},
+		{target("a"), target("a")},
+		{target("a"), target("")},
+		{{ServerURL: "https://live.example.com/app", StreamName: "key"}},
+		{{ServerURL: "rtmp://", StreamName: "key"}},
+	}
+	for _, targets := range invalid {
+		if _, err := session.StartBroadcast(BroadcastOptions{RTMP: targets}); err == nil {
+			t.Errorf("expected an error for RTMP targets %+v", targets)
+		}
+	}
+	if err := validateRTMPTargets([]RTMPTarget{target("a"), target("b"), target("c"), target("d"), target("e")}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStartBroadcastDVR(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Outputs struct {
+				HLS map[string]interface{} `json:"hls"`
+			} `json:"outputs"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Outputs.HLS["dvr"] != true {
+			t.Errorf("unexpected HLS output %v", body.Outputs.HLS)
+		}
+		w.Write([]byte(`{"id":"b1","status":"started","settings":{"hls":{"dvr":true,"lowLatency":false}},
+			"broadcastUrls":{"hls":"https://example.com/b1.m3u8"}}`))
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	broadcast, err := session.StartBroadcast(BroadcastOptions{HLS: &HLSOptions{DVR: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url, ok := broadcast.DVRURL(); !ok || url != "https://example.com/b1.m3u8" {
+		t.Fatalf("unexpected DVR URL %q", url)
+	}
+
+	opts := BroadcastOptions{HLS: &HLSOptions{DVR: true, LowLatency: true}}
+	if _, err = session.StartBroadcast(opts); err == nil {
+		t.Fatal("expected an error for DVR combined with low latency")
+	}
+}
+
+func TestFilterBroadcastsByStatus(t *testing.T) {
+	bs := []Broadcast{
+		{ID: "b1", Status: BroadcastStarted},
+		{ID: "b2", Status: BroadcastStopped},
+		{ID: "b3", Status: BroadcastFailed},
+		{ID: "b4", Status: BroadcastStarted},
+	}
+
+	live := FilterBroadcastsByStatus(bs, BroadcastStarted)
+	if len(live) != 2 || live[0].ID != "b1" || live[1].ID != "b4" {
+		t.Fatalf("unexpected live broadcasts %v", live)
+	}
+
+	terminal := FilterBroadcastsByStatus(bs, BroadcastStopped, BroadcastFailed)
+	if len(terminal) != 2 || terminal[0].ID != "b2" || terminal[1].ID != "b3" {
+		t.Fatalf("unexpected terminal broadcasts %v", terminal)
+	}
+
+	if none := FilterBroadcastsByStatus(bs); len(none) != 0 {
+		t.Fatalf("expected no broadcasts without statuses, got %v", none)
+	}
+}
+
+func TestStartBroadcastMaxDuration(t *testing.T) {
+	session := &Session{SessionID: "s1", tokbox: New("123456", "secret")}
+
+	for _, d := range []int{30, 36001} {
+		_, err := session.StartBroadcast(BroadcastOptions{HLS: &HLSOptions{}, MaxDuration: d})
+		if err == nil || !strings.Contains(err.Error(), "between 60 and 36000 seconds") {
+			t.Fatalf("maxDuration %d: unexpected error %v", d, err)
+		}
+	}
+}
+
+func TestBroadcastStop(t *testing.T) {
+	status := http.StatusOK
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v2/project/123456/broadcast/b1/stop" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			w.Write([]byte(`{"code":409,"message":"broadcast already stopped"}`))
+			return
+		}
+		w.Write([]byte(`{"id":"b1","status":"stopped","updatedAt":1700000060000}`))
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+	broadcast := &Broadcast{ID: "b1", Status: BroadcastStarted, S: session}
+
+	stopped, err := broadcast.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopped.Status != BroadcastStopped || stopped.UpdatedAt != 1700000060000 || stopped.S != session {
+		t.Fatalf("unexpected broadcast %+v", stopped)
+	}
+
+	status = http.StatusConflict
+	if _, err = broadcast.Stop(); err == nil || !strings.Contains(err.Error(), "broadcast already stopped") {
+		t.Fatalf("expected the response message in the error, got %v", err)
+	}
+}
+
+func TestListBroadcasts(t *testing.T) {
+	var query url.Values
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/project/123456/broadcast" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		query = r.URL.Query()
+		w.Write([]byte(`{"count":7,"items":[{"id":"b1","status":"started"},{"id":"b2","status":"stopped"}]}`))
+	})
+
+	broadcasts, total, err := tokbox.ListBroadcasts(5, 2, "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 7 || len(broadcasts) != 2 || broadcasts[0].ID != "b1" {
+		t.Fatalf("unexpected broadcasts %+v, total %d", broadcasts, total)
+	}
+	if query.Get("offset") != "5" || query.Get("count") != "2" || query.Get("sessionId") != "s1" {
+		t.Fatalf("unexpected query %v", query)
+	}
+
+	if _, _, err = tokbox.ListBroadcasts(0, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(query) != 0 {
+		t.Fatalf("expected no query parameters, got %v", query)
+	}
+
+	if _, _, err = tokbox.ListBroadcasts(0, maxBroadcastPageSize+1, ""); err == nil {
+		t.Fatal("expected an error for a count above the API maximum")
+	}
+}
+
+func TestBroadcastStreamMode(t *testing.T) {
+	var bodies []map[string]interface{}
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		if r.Method == "POST" {
+			w.Write([]byte(`{"id":"b1","sessionId":"s1","status":"started","streamMode":"manual"}`))
+			return
+		}
+		if r.Method != "PATCH" || r.URL.Path != "/v2/project/123456/broadcast/b1/streams" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	opts := BroadcastOptions{HLS: &HLSOptions{}, StreamMode: ManualStreamMode}
+	broadcast, err := session.StartBroadcast(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bodies[0]["streamMode"] != "manual" || broadcast.StreamMode != ManualStreamMode {
+		t.Fatalf("unexpected body %v for broadcast %+v", bodies[0], broadcast)
+	}
+
+	if err = broadcast.AddStream("speaker", true, true); err != nil {
+		t.Fatal(err)
+	}
+	if bodies[1]["addStream"] != "speaker" || bodies[1]["hasVideo"] != true {
+		t.Fatalf("unexpected body %v", bodies[1])
+	}
+	if err = broadcast.RemoveStream("speaker"); err != nil {
+		t.Fatal(err)
+	}
+	if bodies[2]["removeStream"] != "speaker" {
+		t.Fatalf("unexpected body %v", bodies[2])
+	}
+
+	auto := &Broadcast{ID: "b2", StreamMode: AutoStreamMode, S: session}
+	if err = auto.AddStream("speaker", true, true); err == nil {
+		t.Fatal("expected an error for a broadcast in auto stream mode")
+	}
+	if _, err = session.StartBroadcast(BroadcastOptions{HLS: &HLSOptions{}, StreamMode: "spotlight"}); err == nil {
+		t.Fatal("expected an error for an unknown stream mode")
+	}
+	if len(bodies) != 3 {
+		t.Fatalf("unexpected requests %v", bodies)
+	}
+}
+
+func TestBroadcastSetLayout(t *testing.T) {
+	var body map[string]interface{}
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/v2/project/123456/broadcast/b1/layout" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+	})
+	broadcast := &Broadcast{ID: "b1", S: &Session{SessionID: "s1", tokbox: tokbox}}
+
+	if err := broadcast.SetLayout("custom", "stream.main {width: 100%;}"); err != nil {
+		t.Fatal(err)
+	}
+	if body["type"] != "custom" || body["stylesheet"] != "stream.main {width: 100%;}" {
+		t.Fatalf("unexpected body %v", body)
+	}
+
+	if err := broadcast.SetLayoutWithScreenshare("bestFit", "", "verticalPresentation"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["stylesheet"]; ok || body["type"] != "bestFit" || body["screenshareType"] != "verticalPresentation" {
+		t.Fatalf("unexpected body %v", body)
+	}
+
+	invalid := [][3]string{
+		{"custom", "", ""},
+		{"pip", "stream {}", ""},
+		{"grid", "", ""},
+		{"pip", "", "verticalPresentation"},
+		{"bestFit", "", "custom"},
+	}
+	for _, c := range invalid {
+		if err := broadcast.SetLayoutWithScreenshare(c[0], c[1], c[2]); err == nil {
+			t.Errorf("expected an error for layout %q, stylesheet %q and screenshare layout %q", c[0], c[1], c[2])
+		}
+	}
+}
+
+func TestStopListedBroadcast(t *testing.T) {
+	var paths []string
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if r.Method == "GET" {
+			w.Write([]byte(`{"count":1,"items":[{"id":"b1","sessionId":"s1","status":"started"}]}`))
+			return
+		}
+		w.Write([]byte(`{"id":"` + strings.Split(r.URL.Path, "/")[5] + `","sessionId":"s1","status":"stopped"}`))
+	})
+
+	broadcasts, _, err := tokbox.ListBroadcasts(0, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if broadcasts[0].S == nil || broadcasts[0].S.SessionID != "s1" {
+		t.Fatalf("listed broadcast is not bound to its session: %+v", broadcasts[0])
+	}
+	stopped, err := broadcasts[0].Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopped.Status != BroadcastStopped || stopped.S != broadcasts[0].S {
+		t.Fatalf("unexpected broadcast %+v", stopped)
+	}
+
+	if stopped, err = tokbox.StopBroadcast("b2"); err != nil {
+		t.Fatal(err)
+	}
+	if stopped.ID != "b2" || stopped.S == nil || stopped.S.SessionID != "s1" {
+		t.Fatalf("unexpected broadcast %+v", stopped)
+	}
+	if strings.Join(paths, ",") != "GET /v2/project/123456/broadcast,POST /v2/project/123456/broadcast/b1/stop,POST /v2/project/123456/broadcast/b2/stop" {
+		t.Fatalf("unexpected requests %v", paths)
+	}
+
+	unbound := &Broadcast{ID: "b3", StreamMode: ManualStreamMode}
+	if _, err = unbound.Stop(); err == nil {
+		t.Fatal("expected an error for a broadcast without session")
+	}
+	if err = unbound.AddStream("st1", true, true); err == nil {
+		t.Fatal("expected an error for a broadcast without session")
+	}
+	if err = unbound.SetLayout("pip", ""); err == nil {
+		t.Fatal("expected an error for a broadcast without session")
+	}
+}
+
+func TestListBroadcastStreams(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" || r.URL.Path != "/v2/project/123456/broadcast/b1" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"id":"b1","status":"started","streamMode":"manual","streams":[
+			{"streamId":"st1","hasAudio":true,"hasVideo":true},
+			{"streamId":"st2","hasAudio":false,"hasVideo":true}]}`))
+	})
+
+	streams, err := tokbox.ListBroadcastStreams("b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 2 {
+		t.Fatalf("got %d streams, want 2", len(streams))
+	}
+	if streams[1] != (IncludedStream{StreamID: "st2", HasVideo: true}) {
+		t.Fatalf("unexpected stream %+v", streams[1])
+	}
+}
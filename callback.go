@@ -0,0 +1,76 @@
+package tokbox
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidSignature is returned by VerifyCallbackSignature when a callback
+// was not signed with the project secret
+var ErrInvalidSignature = errors.New("tokbox: invalid callback signature")
+
+// CallbackConnection is the connection a session monitoring callback refers to
+type CallbackConnection struct {
+	ID        string `json:"id"`
+	CreatedAt int64  `json:"createdAt"`
+	Data      string `json:"data"`
+}
+
+// CallbackStream is the stream a session monitoring callback refers to
+type CallbackStream struct {
+	ID         string             `json:"id"`
+	Connection CallbackConnection `json:"connection"`
+	CreatedAt  int64              `json:"createdAt"`
+	Name       string             `json:"name"`
+	VideoType  string             `json:"videoType"`
+}
+
+// CallbackEvent holds the fields common to the session monitoring callbacks
+type CallbackEvent struct {
+	SessionID string `json:"sessionId"`
+	ProjectID string `json:"projectId"`
+	// Event is e.g. "connectionCreated", "connectionDestroyed", "streamCreated" or "streamDestroyed"
+	Event     string `json:"event"`
+	Reason    string `json:"reason"`
+	Timestamp int64  `json:"timestamp"`
+	// Connection is set for connection events
+	Connection *CallbackConnection `json:"connection"`
+	// Stream is set for stream events
+	Stream *CallbackStream `json:"stream"`
+}
+
+// VerifyCallbackSignature checks that a callback body was signed with the
+// project secret. signatureHeader is the hex encoded HMAC-SHA256 of the body,
+// optionally prefixed with "sha256=". It returns ErrInvalidSignature if the
+// signature does not match.
+func (t *Tokbox) VerifyCallbackSignature(signatureHeader string, body []byte) error {
+	signature, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(signatureHeader), "sha256="))
+	if err != nil || len(signature) == 0 {
+		return ErrInvalidSignature
+	}
+
+	h := hmac.New(sha256.New, []byte(t.partnerSecret))
+	h.Write(body)
+	if !hmac.Equal(signature, h.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ParseCallback decodes a session monitoring callback. Verify its signature
+// with VerifyCallbackSignature first.
+func ParseCallback(body []byte) (*CallbackEvent, error) {
+	var event CallbackEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("invalid callback body: %w", err)
+	}
+	if len(event.Event) == 0 {
+		return nil, fmt.Errorf("callback body has no event")
+	}
+	return &event, nil
+}
@@ -0,0 +1,46 @@
+package tokbox
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestCallback(t *testing.T) {
+	tokbox := New("123456", "secret")
+	body := []byte(`{"sessionId":"s1","projectId":"123456","event":"streamCreated","timestamp":1470257688309,` +
+		`"stream":{"id":"st1","connection":{"id":"c1","data":"user=42"},"videoType":"camera"}}`)
+
+	h := hmac.New(sha256.New, []byte("secret"))
+	h.Write(body)
+	signature := hex.EncodeToString(h.Sum(nil))
+
+	if err := tokbox.VerifyCallbackSignature(signature, body); err != nil {
+		t.Fatal(err)
+	}
+	if err := tokbox.VerifyCallbackSignature("sha256="+signature, body); err != nil {
+		t.Fatal(err)
+	}
+	for _, header := range []string{"", "not hex", signature[:10]} {
+		if err := tokbox.VerifyCallbackSignature(header, body); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("expected ErrInvalidSignature for %q, got %v", header, err)
+		}
+	}
+	if err := New("123456", "other").VerifyCallbackSignature(signature, body); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for another secret, got %v", err)
+	}
+
+	event, err := ParseCallback(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.SessionID != "s1" || event.Event != "streamCreated" || event.Connection != nil ||
+		event.Stream == nil || event.Stream.ID != "st1" || event.Stream.Connection.Data != "user=42" {
+		t.Fatalf("unexpected event %+v", event)
+	}
+	if _, err = ParseCallback([]byte(`{"sessionId":"s1"}`)); err == nil {
+		t.Fatal("expected an error for a body without an event")
+	}
+}
@@ -0,0 +1,34 @@
+package tokbox
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+// EvictUser disconnects a connection from the session. It is the practical
+// way to revoke access: tokens are immutable once minted and OpenTok can not
+// revoke them, so a token stays usable until it expires. To keep an evicted
+// user out, stop handing them new tokens and mint short-lived ones.
+func (s *Session) EvictUser(connectionID string, ctx ...context.Context) error {
+	return s.ForceDisconnect(connectionID, ctx...)
+}
+
+// ForceDisconnect disconnects a client from the session. If the client is
+// not connected, e.g. because it already left, the error wraps
+// ErrConnectionNotFound.
+func (s *Session) ForceDisconnect(connectionID string, ctx ...context.Context) error {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+	return s.disconnect(ctx[0], connectionID)
+}
+
+// disconnect force-disconnects a connection from the session
+func (s *Session) disconnect(ctx context.Context, connectionID string) error {
+	if len(connectionID) == 0 {
+		return fmt.Errorf("connection ID must not be empty")
+	}
+	path := s.tokbox.apiPath(apiConnectionURL, s.SessionID, connectionID)
+	return disconnectErrors.wrap(s.tokbox.doJSON(ctx, "DELETE", path, nil, nil))
+}
@@ -0,0 +1,38 @@
+package tokbox
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestEvictUser(t *testing.T) {
+	var got string
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Method + " " + r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	if err := session.EvictUser("c1"); err != nil {
+		t.Fatal(err)
+	}
+	if got != "DELETE /v2/project/123456/session/s1/connection/c1" {
+		t.Fatalf("unexpected request %s", got)
+	}
+	if err := session.EvictUser(""); err == nil {
+		t.Fatal("expected an error for an empty connection ID")
+	}
+}
+
+func TestForceDisconnectNotFound(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	err := session.ForceDisconnect("c1")
+	if !errors.Is(err, ErrConnectionNotFound) {
+		t.Fatalf("expected ErrConnectionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package tokbox
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+// End tears the session down when a meeting is over: it stops the archives
+// being recorded and the broadcasts that are live, reading every page of
+// them, then disconnects
+// connectionIDs from the session. OpenTok has no endpoint listing the
+// connections of a session, so the caller passes the connections it tracks.
+// End is best-effort, it carries on after a failure and returns all errors
+// joined. Archives, broadcasts and connections that are already gone are not
+// errors, so End is safe to call more than once.
+// On Google App Engine ctx must be an App Engine context.
+func (s *Session) End(ctx context.Context, connectionIDs ...string) error {
+	var errs []error
+
+	archives, err := s.tokbox.listAllArchives(ctx, s.SessionID)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("list archives: %w", err))
+	}
+	for _, archive := range archives {
+		if archive.Status != "started" && archive.Status != "paused" {
+			continue
+		}
+		_, err := s.StopArchivingByID(archive.ID, ctx)
+		if err != nil && !errors.Is(err, ErrArchiveNotStarted) && !errors.Is(err, ErrNotFound) {
+			errs = append(errs, fmt.Errorf("stop archive %s: %w", archive.ID, err))
+		}
+	}
+
+	broadcasts, err := s.tokbox.listAllBroadcasts(ctx, s.SessionID)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("list broadcasts: %w", err))
+	}
+	for _, broadcast := range FilterBroadcastsByStatus(broadcasts, BroadcastStarted) {
+		_, err := s.tokbox.stopBroadcast(ctx, broadcast.ID)
+		if err != nil && !isGone(err) {
+			errs = append(errs, fmt.Errorf("stop broadcast %s: %w", broadcast.ID, err))
+		}
+	}
+
+	for _, connectionID := range connectionIDs {
+		err := s.disconnect(ctx, connectionID)
+		if err != nil && !errors.Is(err, ErrConnectionNotFound) {
+			errs = append(errs, fmt.Errorf("disconnect %s: %w", connectionID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// isGone reports whether err says that the resource no longer exists or is
+// no longer active (404 Not Found or 409 Conflict)
+func isGone(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == 404 || apiErr.StatusCode == 409)
+}
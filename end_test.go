@@ -0,0 +1,129 @@
+package tokbox
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSessionEnd(t *testing.T) {
+	var lock sync.Mutex
+	var calls []string
+	stopped := map[string]bool{}
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		call := r.Method + " " + r.URL.Path
+		lock.Lock()
+		defer lock.Unlock()
+		switch {
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/archive"):
+			status := "started"
+			if stopped["a1"] {
+				status = "stopped"
+			}
+			w.Write([]byte(`{"count":2,"items":[{"id":"a1","status":"` + status + `"},{"id":"a2","status":"available"}]}`))
+			return
+		case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/broadcast"):
+			status := "started"
+			if stopped["b1"] {
+				status = "stopped"
+			}
+			w.Write([]byte(`{"count":1,"items":[{"id":"b1","status":"` + status + `"}]}`))
+			return
+		case strings.HasSuffix(r.URL.Path, "/a1/stop"):
+			stopped["a1"] = true
+			w.Write([]byte(`{"id":"a1","status":"stopped"}`))
+		case strings.HasSuffix(r.URL.Path, "/b1/stop"):
+			stopped["b1"] = true
+			w.Write([]byte(`{"id":"b1","status":"stopped"}`))
+		case strings.HasSuffix(r.URL.Path, "/connection/c1"):
+			if stopped["c1"] {
+				w.WriteHeader(http.StatusNotFound)
+			} else {
+				stopped["c1"] = true
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		calls = append(calls, call)
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	if err := session.End(nil, "c1"); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(calls)
+	want := []string{
+		"DELETE /v2/project/123456/session/s1/connection/c1",
+		"POST /v2/project/123456/archive/a1/stop",
+		"POST /v2/project/123456/broadcast/b1/stop",
+	}
+	if strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+
+	// Ending again finds nothing left to stop
+	calls = nil
+	if err := session.End(nil, "c1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+}
+
+func TestSessionEndPages(t *testing.T) {
+	var lock sync.Mutex
+	var stops []string
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		if r.Method == "POST" {
+			stops = append(stops, r.URL.Path)
+			w.Write([]byte(`{"status":"stopped"}`))
+			return
+		}
+		kind := "a"
+		if strings.HasSuffix(r.URL.Path, "/broadcast") {
+			kind = "b"
+		}
+		// Only the second page holds a recording that is still running
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		status := "stopped"
+		if offset > 0 {
+			status = "started"
+		}
+		fmt.Fprintf(w, `{"count":1001,"items":[{"id":"%s%d","status":"%s"}]}`, kind, offset, status)
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	if err := session.End(nil); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(stops)
+	want := "/v2/project/123456/archive/a1000/stop,/v2/project/123456/broadcast/b1000/stop"
+	if strings.Join(stops, ",") != want {
+		t.Fatalf("stops = %v, want %s", stops, want)
+	}
+}
+
+func TestSessionEndAggregatesErrors(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	err := session.End(nil, "c1")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, part := range []string{"list archives", "list broadcasts", "disconnect c1"} {
+		if !strings.Contains(err.Error(), part) {
+			t.Errorf("error %q does not mention %q", err, part)
+		}
+	}
+}
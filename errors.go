@@ -0,0 +1,168 @@
+package tokbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is wrapped by the APIError returned when OpenTok responds with
+// 404 Not Found, so callers can check for it with errors.Is
+var ErrNotFound = errors.New("tokbox: resource not found")
+
+// Sentinel errors wrapped by an APIError when OpenTok reports a known
+// condition, check for them with errors.Is
+var (
+	// ErrArchiveAlreadyStarted The session is already being recorded.
+	ErrArchiveAlreadyStarted = errors.New("tokbox: archive already started")
+	// ErrArchiveNotStarted The archive is not being recorded, e.g. it was already stopped.
+	ErrArchiveNotStarted = errors.New("tokbox: archive not started")
+	// ErrArchiveNotFound There is no archive with the given ID.
+	ErrArchiveNotFound = errors.New("tokbox: archive not found")
+	// ErrConnectionNotFound The client is not connected to the session, e.g. it already left.
+	ErrConnectionNotFound = errors.New("tokbox: connection not found")
+	// ErrTranscriptionNotEnabled Archive transcription is not enabled for the project.
+	ErrTranscriptionNotEnabled = errors.New("tokbox: transcription not enabled")
+	// ErrInvalidSession The session ID is malformed or belongs to another project.
+	ErrInvalidSession = errors.New("tokbox: invalid session ID")
+	// ErrAuthentication The API key or secret is wrong, or the project is suspended.
+	ErrAuthentication = errors.New("tokbox: authentication failed")
+)
+
+// errorCodes maps the OpenTok error codes found in error bodies to sentinel errors
+var errorCodes = map[string]error{
+	"1004":  ErrAuthentication,
+	"1005":  ErrInvalidSession,
+	"10160": ErrArchiveAlreadyStarted,
+}
+
+// statusErrors maps the status codes documented for an endpoint to sentinel
+// errors, as OpenTok reuses status codes with a different meaning per endpoint
+type statusErrors map[int]error
+
+var (
+	startArchiveErrors  = statusErrors{http.StatusConflict: ErrArchiveAlreadyStarted}
+	stopArchiveErrors   = statusErrors{http.StatusNotFound: ErrArchiveNotFound, http.StatusConflict: ErrArchiveNotStarted}
+	getArchiveErrors    = statusErrors{http.StatusNotFound: ErrArchiveNotFound}
+	deleteArchiveErrors = statusErrors{http.StatusNotFound: ErrArchiveNotFound}
+	disconnectErrors    = statusErrors{http.StatusNotFound: ErrConnectionNotFound}
+)
+
+// wrap attaches the sentinel error matching the status code to err, if err
+// is an *APIError
+func (m statusErrors) wrap(err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.sentinel == nil {
+		apiErr.sentinel = m[apiErr.StatusCode]
+	}
+	return err
+}
+
+// wrapInvalidSession attaches ErrInvalidSession to err when OpenTok rejects
+// the session ID of a call. Starting an archive or a broadcast, dialing and
+// signaling report it with a 400 mentioning the session, a 400 can also mean
+// that another parameter is invalid.
+func wrapInvalidSession(err error) error {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.sentinel != nil || apiErr.StatusCode != http.StatusBadRequest {
+		return err
+	}
+	if strings.Contains(strings.ToLower(apiErr.Message+" "+apiErr.Description+" "+apiErr.Body), "session") {
+		apiErr.sentinel = ErrInvalidSession
+	}
+	return err
+}
+
+// wrapTranscriptionError attaches ErrTranscriptionNotEnabled to err when
+// OpenTok rejects a transcription request. OpenTok reports it with a 400 or
+// 403 mentioning transcription, the status code alone is ambiguous.
+func wrapTranscriptionError(err error) error {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.sentinel != nil {
+		return err
+	}
+	if apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusForbidden {
+		return err
+	}
+	if strings.Contains(strings.ToLower(apiErr.Message+" "+apiErr.Description+" "+apiErr.Body), "transcription") {
+		apiErr.sentinel = ErrTranscriptionNotEnabled
+	}
+	return err
+}
+
+// APIError is returned when OpenTok responds with an unexpected status code.
+// Code, Message and Description are decoded from the JSON error body, when present.
+type APIError struct {
+	StatusCode  int
+	Code        string // OpenTok error code, if any. OpenTok sends numeric and string codes.
+	Message     string // Short summary of the error
+	Description string // Longer explanation, suitable for operators
+	Body        string // Raw response body
+
+	sentinel   error         // Set by the endpoint that returned the error
+	retryAfter time.Duration // From the Retry-After header, if any
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("Tokbox returns error code: %v", e.StatusCode)
+	switch {
+	case len(e.Message) > 0 && len(e.Description) > 0:
+		return fmt.Sprintf("%s. Message: %s. Description: %s", msg, e.Message, e.Description)
+	case len(e.Message) > 0:
+		return fmt.Sprintf("%s. Message: %s", msg, e.Message)
+	case len(e.Description) > 0:
+		return fmt.Sprintf("%s. Description: %s", msg, e.Description)
+	case len(e.Body) > 0:
+		return fmt.Sprintf("%s. Message: %s", msg, e.Body)
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel errors matching the error code and the status
+// code, unknown codes match none. OpenTok answers 403 Forbidden when
+// authentication fails, so it matches ErrAuthentication unless the message
+// already identified a more specific cause, e.g. ErrTranscriptionNotEnabled.
+func (e *APIError) Unwrap() []error {
+	errs := []error{}
+	if e.sentinel != nil {
+		errs = append(errs, e.sentinel)
+	}
+	if err, ok := errorCodes[e.Code]; ok && err != e.sentinel {
+		errs = append(errs, err)
+	}
+	if e.StatusCode == http.StatusNotFound {
+		errs = append(errs, ErrNotFound)
+	}
+	if e.StatusCode == http.StatusForbidden && e.Code != "1004" && e.sentinel == nil {
+		errs = append(errs, ErrAuthentication)
+	}
+	return errs
+}
+
+// newAPIError builds an APIError from an unsuccessful response
+func newAPIError(res *http.Response) error {
+	bodyBytes, _ := io.ReadAll(res.Body)
+	apiErr := &APIError{
+		StatusCode: res.StatusCode,
+		Body:       string(bodyBytes),
+		retryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+	}
+
+	// The body is not always JSON, in that case only the raw body is kept
+	var envelope struct {
+		Code        json.RawMessage `json:"code"`
+		Message     string          `json:"message"`
+		Description string          `json:"description"`
+	}
+	if err := json.Unmarshal(bodyBytes, &envelope); err == nil {
+		apiErr.Message, apiErr.Description = envelope.Message, envelope.Description
+		if code := string(envelope.Code); code != "null" {
+			apiErr.Code = strings.Trim(code, `"`)
+		}
+	}
+	return apiErr
+}
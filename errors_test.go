@@ -0,0 +1,164 @@
+package tokbox
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNotFoundError(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"not found"}`))
+	})
+
+	_, err := tokbox.NewSession("", MediaRouter, ManualArchive)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an *APIError, got %T", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("status code = %d, want 404", apiErr.StatusCode)
+	}
+}
+
+func TestAPIErrorIsNotNotFound(t *testing.T) {
+	err := &APIError{StatusCode: http.StatusForbidden}
+	if errors.Is(err, ErrNotFound) {
+		t.Fatal("403 must not match ErrNotFound")
+	}
+}
+
+func TestAPIErrorDescription(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":15204,"message":"Invalid session ID","description":"The session ID could not be parsed. Check that it was created for this project."}`))
+	})
+
+	_, err := tokbox.ListArchivesFunc(0, 0, "bogus", func(*Archive) error { return nil })
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an *APIError, got %v", err)
+	}
+	if apiErr.Message != "Invalid session ID" || apiErr.Code != "15204" || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unexpected error %+v", apiErr)
+	}
+	if apiErr.Description != "The session ID could not be parsed. Check that it was created for this project." {
+		t.Fatalf("description = %q", apiErr.Description)
+	}
+	if !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), apiErr.Message) || !strings.Contains(err.Error(), apiErr.Description) {
+		t.Fatalf("unexpected error message %q", err.Error())
+	}
+}
+
+func TestAPIErrorStringCode(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"code":"invalid_credentials","message":"Invalid credentials"}`))
+	})
+
+	err := tokbox.Do(nil, "GET", "/v2/project/123456/archive", nil, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "invalid_credentials" || apiErr.Body == "" {
+		t.Fatalf("unexpected error %+v", err)
+	}
+}
+
+func TestAPIErrorPlainBody(t *testing.T) {
+	err := &APIError{StatusCode: http.StatusBadGateway, Body: "Bad Gateway"}
+	if err.Error() != "Tokbox returns error code: 502. Message: Bad Gateway" {
+		t.Fatalf("unexpected error message %q", err.Error())
+	}
+}
+
+func TestAPIErrorSentinels(t *testing.T) {
+	status := http.StatusConflict
+	code := 0
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"code":%d,"message":"conflict"}`, code)
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	_, err := session.StartArchiving(true, true)
+	if !errors.Is(err, ErrArchiveAlreadyStarted) {
+		t.Fatalf("expected ErrArchiveAlreadyStarted, got %v", err)
+	}
+
+	_, err = session.StopArchivingByID("a1")
+	if !errors.Is(err, ErrArchiveNotStarted) || errors.Is(err, ErrArchiveAlreadyStarted) {
+		t.Fatalf("expected only ErrArchiveNotStarted, got %v", err)
+	}
+
+	status = http.StatusNotFound
+	_, err = tokbox.ListArchiveStreams("a1")
+	if !errors.Is(err, ErrArchiveNotFound) || !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrArchiveNotFound and ErrNotFound, got %v", err)
+	}
+
+	status, code = http.StatusBadRequest, 10160
+	err = tokbox.Do(nil, "POST", "/v2/project/123456/archive", nil, nil)
+	if !errors.Is(err, ErrArchiveAlreadyStarted) {
+		t.Fatalf("expected ErrArchiveAlreadyStarted from the error code, got %v", err)
+	}
+
+	code = 1
+	err = tokbox.Do(nil, "POST", "/v2/project/123456/archive", nil, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "1" || errors.Is(err, ErrArchiveAlreadyStarted) {
+		t.Fatalf("expected a plain APIError for an unknown code, got %v", err)
+	}
+}
+
+func TestInvalidSessionError(t *testing.T) {
+	message := "Invalid session ID"
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"code":400,"message":%q}`, message)
+	})
+	session := &Session{SessionID: "bogus", tokbox: tokbox}
+
+	calls := map[string]func() error{
+		"StartArchiving": func() error { _, err := session.StartArchiving(true, true); return err },
+		"StartBroadcast": func() error { _, err := session.StartBroadcast(BroadcastOptions{HLS: &HLSOptions{}}); return err },
+		"Dial":           func() error { _, err := session.Dial("sip:user@example.com", "T1==abc", SIPOptions{}); return err },
+		"Signal":         func() error { return session.Signal("chat", "hi") },
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, ErrInvalidSession) {
+			t.Errorf("%s: expected ErrInvalidSession, got %v", name, err)
+		}
+	}
+
+	// Other bad requests are not reported as an invalid session
+	message = "Invalid layout options"
+	if _, err := session.StartArchiving(true, true); err == nil || errors.Is(err, ErrInvalidSession) {
+		t.Fatalf("expected a plain bad request, got %v", err)
+	}
+}
+
+func TestAPIErrorCodes(t *testing.T) {
+	cases := []struct {
+		err  *APIError
+		want error
+	}{
+		{&APIError{StatusCode: http.StatusBadRequest, Code: "1005"}, ErrInvalidSession},
+		{&APIError{StatusCode: http.StatusBadRequest, Code: "1004"}, ErrAuthentication},
+		{&APIError{StatusCode: http.StatusForbidden}, ErrAuthentication},
+		{&APIError{StatusCode: http.StatusConflict, Code: "10160"}, ErrArchiveAlreadyStarted},
+	}
+	for _, c := range cases {
+		if !errors.Is(c.err, c.want) {
+			t.Errorf("%+v does not match %v", c.err, c.want)
+		}
+	}
+	if errors.Is(&APIError{StatusCode: http.StatusBadRequest}, ErrInvalidSession) {
+		t.Fatal("a bare 400 must not match ErrInvalidSession")
+	}
+}
@@ -0,0 +1,75 @@
+package tokbox
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// UnmarshalJSON decodes a session and keeps the keys it does not know in RawExtra
+func (s *Session) UnmarshalJSON(data []byte) error {
+	type plain Session
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, s)
+	s.RawExtra = extra
+	return err
+}
+
+// UnmarshalJSON decodes an archive and keeps the keys it does not know in RawExtra
+func (archive *Archive) UnmarshalJSON(data []byte) error {
+	type plain Archive
+	if err := json.Unmarshal(data, (*plain)(archive)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, archive)
+	archive.RawExtra = extra
+	return err
+}
+
+// UnmarshalJSON decodes a broadcast and keeps the keys it does not know in RawExtra
+func (b *Broadcast) UnmarshalJSON(data []byte) error {
+	type plain Broadcast
+	if err := json.Unmarshal(data, (*plain)(b)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, b)
+	b.RawExtra = extra
+	return err
+}
+
+// unknownFields returns the keys of the JSON object data that do not map to
+// a field of the struct v points to, or nil if there are none. Keys are
+// matched case-insensitively, like encoding/json does.
+func unknownFields(data []byte, v interface{}) (map[string]json.RawMessage, error) {
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	typ := reflect.TypeOf(v).Elem()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		for key := range all {
+			if strings.EqualFold(key, name) {
+				delete(all, key)
+			}
+		}
+	}
+
+	if len(all) == 0 {
+		return nil, nil
+	}
+	return all, nil
+}
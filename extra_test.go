@@ -0,0 +1,39 @@
+package tokbox
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRawExtra(t *testing.T) {
+	var archive Archive
+	data := `{"id":"a1","SessionId":"s1","multiArchiveTag":"tag","maxBitrate":2000000}`
+	if err := json.Unmarshal([]byte(data), &archive); err != nil {
+		t.Fatal(err)
+	}
+	if archive.ID != "a1" || archive.SessionID != "s1" {
+		t.Fatalf("unexpected archive %+v", archive)
+	}
+	if len(archive.RawExtra) != 2 || string(archive.RawExtra["multiArchiveTag"]) != `"tag"` {
+		t.Fatalf("unexpected extra fields %v", archive.RawExtra)
+	}
+
+	var broadcast Broadcast
+	if err := json.Unmarshal([]byte(`{"id":"b1","multiBroadcastTag":"tag"}`), &broadcast); err != nil {
+		t.Fatal(err)
+	}
+	if broadcast.ID != "b1" || string(broadcast.RawExtra["multiBroadcastTag"]) != `"tag"` {
+		t.Fatalf("unexpected broadcast %+v", broadcast)
+	}
+
+	var sessions []Session
+	if err := json.Unmarshal([]byte(`[{"session_id":"s1"},{"session_id":"s2","region":"eu"}]`), &sessions); err != nil {
+		t.Fatal(err)
+	}
+	if sessions[0].RawExtra != nil || string(sessions[1].RawExtra["region"]) != `"eu"` {
+		t.Fatalf("unexpected sessions %+v", sessions)
+	}
+	if sessions[0].Equal(&sessions[1]) {
+		t.Fatal("expected sessions with different data not to be equal")
+	}
+}
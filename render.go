@@ -0,0 +1,156 @@
+package tokbox
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"golang.org/x/net/context"
+)
+
+// RenderOptions holds the settings used to start an Experience Composer render
+type RenderOptions struct {
+	// MaxDuration is the maximum duration of the render in seconds, from
+	// 60 to 36000. 0 uses the OpenTok default of 2 hours.
+	MaxDuration int
+	// Resolution of the rendered page, the OpenTok default is used when empty
+	Resolution Resolution
+	// Name is the name of the stream the render publishes to the session
+	Name string
+}
+
+// Render is an Experience Composer rendering a web page into a session
+type Render struct {
+	ID         string `json:"id"`
+	SessionID  string `json:"sessionId"`
+	ProjectID  string `json:"projectId"`
+	CreatedAt  int    `json:"createdAt"`
+	UpdatedAt  int    `json:"updatedAt"`
+	URL        string `json:"url"`
+	Resolution string `json:"resolution"`
+	Status     string `json:"status"` // "starting", "started", "stopped" or "failed"
+	StreamID   string `json:"streamId"`
+	Reason     string `json:"reason"`
+}
+
+// maxRenderPageSize is the maximum number of renders OpenTok returns per page
+const maxRenderPageSize = 1000
+
+// validate checks that the options describe a render OpenTok can start
+func (opts RenderOptions) validate() error {
+	if len(opts.Resolution) > 0 && !opts.Resolution.Valid() {
+		return fmt.Errorf("unsupported resolution %q", opts.Resolution)
+	}
+	return validateMaxDuration("render", opts.MaxDuration, renderDurationLimits)
+}
+
+// StartRender starts an Experience Composer that loads pageURL and publishes
+// the rendered page to the session as a stream. token is a token of the
+// session the composer connects with.
+func (s *Session) StartRender(pageURL, token string, opts RenderOptions, ctx ...context.Context) (*Render, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if len(pageURL) == 0 {
+		return nil, fmt.Errorf("render URL must not be empty")
+	}
+	if len(token) == 0 {
+		return nil, fmt.Errorf("token must not be empty")
+	}
+
+	type properties struct {
+		Name string `json:"name,omitempty"`
+	}
+	values := struct {
+		SessionID   string      `json:"sessionId"`
+		Token       string      `json:"token"`
+		URL         string      `json:"url"`
+		MaxDuration int         `json:"maxDuration,omitempty"`
+		Resolution  Resolution  `json:"resolution,omitempty"`
+		Properties  *properties `json:"properties,omitempty"`
+	}{
+		SessionID:   s.SessionID,
+		Token:       token,
+		URL:         pageURL,
+		MaxDuration: opts.MaxDuration,
+		Resolution:  opts.Resolution,
+	}
+	if len(opts.Name) > 0 {
+		values.Properties = &properties{opts.Name}
+	}
+
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	var render Render
+	path := s.tokbox.apiPath(apiStartRenderURL)
+	if err := s.tokbox.doJSON(ctx[0], "POST", path, values, &render); err != nil {
+		return nil, err
+	}
+	return &render, nil
+}
+
+// StopRender stops an Experience Composer
+func (t *Tokbox) StopRender(renderID string, ctx ...context.Context) error {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+	path := t.apiPath(apiRenderURL, renderID)
+	return t.doJSON(ctx[0], "DELETE", path, nil, nil)
+}
+
+// GetRender fetches an Experience Composer by its ID
+func (t *Tokbox) GetRender(renderID string, ctx ...context.Context) (*Render, error) {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	var render Render
+	path := t.apiPath(apiRenderURL, renderID)
+	if err := t.doJSON(ctx[0], "GET", path, nil, &render); err != nil {
+		return nil, err
+	}
+	return &render, nil
+}
+
+// ListRender returns a page of the Experience Composers of the project and
+// the total number reported by OpenTok. offset and count are ignored when 0,
+// count can be at most 1000.
+func (t *Tokbox) ListRender(offset, count int, ctx ...context.Context) ([]Render, int, error) {
+	if offset < 0 {
+		return nil, 0, fmt.Errorf("invalid offset %d", offset)
+	}
+	if count < 0 || count > maxRenderPageSize {
+		return nil, 0, fmt.Errorf("invalid count %d, it must be between 0 and %d", count, maxRenderPageSize)
+	}
+
+	params := url.Values{}
+	if offset > 0 {
+		params.Add("offset", strconv.Itoa(offset))
+	}
+	if count > 0 {
+		params.Add("count", strconv.Itoa(count))
+	}
+
+	path := t.apiPath(apiListRendersURL)
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	var response struct {
+		Count int      `json:"count"`
+		Items []Render `json:"items"`
+	}
+	if err := t.doJSON(ctx[0], "GET", path, nil, &response); err != nil {
+		return nil, 0, err
+	}
+	if response.Items == nil {
+		response.Items = []Render{}
+	}
+	return response.Items, response.Count, nil
+}
@@ -0,0 +1,68 @@
+package tokbox
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	var body map[string]interface{}
+	var requests []string
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch {
+		case r.Method == "POST":
+			json.NewDecoder(r.Body).Decode(&body)
+			w.Write([]byte(`{"id":"r1","sessionId":"s1","status":"starting","url":"https://example.com/page"}`))
+		case r.Method == "GET" && r.URL.Path == "/v2/project/123456/render/r1":
+			w.Write([]byte(`{"id":"r1","status":"started","streamId":"st1"}`))
+		case r.Method == "GET":
+			w.Write([]byte(`{"count":3,"items":[{"id":"r1"},{"id":"r2"}]}`))
+		case r.Method == "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	opts := RenderOptions{MaxDuration: 1800, Resolution: HDLandscape, Name: "Composed"}
+	render, err := session.StartRender("https://example.com/page", "T1==abc", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	properties, _ := body["properties"].(map[string]interface{})
+	if render.ID != "r1" || body["url"] != "https://example.com/page" || body["resolution"] != "1280x720" ||
+		body["maxDuration"] != float64(1800) || properties["name"] != "Composed" {
+		t.Fatalf("unexpected render %+v for body %v", render, body)
+	}
+
+	if render, err = tokbox.GetRender("r1"); err != nil || render.StreamID != "st1" {
+		t.Fatalf("unexpected render %+v, %v", render, err)
+	}
+	renders, total, err := tokbox.ListRender(0, 2)
+	if err != nil || total != 3 || len(renders) != 2 {
+		t.Fatalf("unexpected renders %+v, total %d, %v", renders, total, err)
+	}
+	if err = tokbox.StopRender("r1"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"POST /v2/project/123456/render",
+		"GET /v2/project/123456/render/r1",
+		"GET /v2/project/123456/render?count=2",
+		"DELETE /v2/project/123456/render/r1",
+	}
+	for i := range want {
+		if i >= len(requests) || requests[i] != want[i] {
+			t.Fatalf("unexpected requests %v", requests)
+		}
+	}
+
+	if _, err = session.StartRender("https://example.com/page", "T1==abc", RenderOptions{MaxDuration: 40000}); err == nil {
+		t.Fatal("expected an error for a maxDuration above the limit")
+	}
+	if _, _, err = tokbox.ListRender(0, maxRenderPageSize+1); err == nil {
+		t.Fatal("expected an error for a count above the API maximum")
+	}
+}
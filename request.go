@@ -0,0 +1,236 @@
+package tokbox
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// RequestOption customizes a single API request
+type RequestOption func(req *http.Request)
+
+// WithHeader sets a header on a single API request, e.g. a correlation ID.
+// It overrides a header of the same name set with SetExtraHeaders.
+func WithHeader(key, value string) RequestOption {
+	return func(req *http.Request) {
+		req.Header.Set(key, value)
+	}
+}
+
+// WithTokenAuth authenticates a single API request with a client token minted
+// by Session.Token, sent as X-TB-TOKEN-AUTH, instead of the project JWT sent
+// as X-OPENTOK-AUTH. None of the methods of this package need it: sessions,
+// archives, broadcasts, streams, signals, connections, SIP, render and
+// Audio Connector calls all authenticate with the project JWT. It only
+// exists for requests sent with Do to endpoints outside this package that
+// act on behalf of a client.
+func WithTokenAuth(token string) RequestOption {
+	return func(req *http.Request) {
+		req.Header.Del("X-OPENTOK-AUTH")
+		req.Header.Set("X-TB-TOKEN-AUTH", token)
+	}
+}
+
+// SetExtraHeaders sets headers added to every API request
+func (t *Tokbox) SetExtraHeaders(headers http.Header) {
+	t.extraHeaders = headers.Clone()
+}
+
+// Do sends an authenticated request to path, relative to the API host, and
+// decodes the JSON response into out unless out is nil. If body is not nil it
+// is sent JSON encoded. Use it to call endpoints this package does not cover
+// or to customize a single request with opts.
+// On Google App Engine ctx must be an App Engine context.
+func (t *Tokbox) Do(ctx context.Context, method, path string, body, out interface{}, opts ...RequestOption) error {
+	return t.doJSON(ctx, method, path, body, out, opts...)
+}
+
+// SetProjectPath replaces the "/v2/project/%s" prefix of the project API
+// paths, to target OpenTok-compatible servers with a different layout. The
+// template must contain a single %s, which is replaced with the API key.
+// An empty template restores the default.
+func (t *Tokbox) SetProjectPath(template string) error {
+	if len(template) > 0 && (strings.Count(template, "%s") != 1 || strings.Count(template, "%") != 1) {
+		return fmt.Errorf("project path %q must contain exactly one %%s placeholder for the API key", template)
+	}
+	t.projectPath = strings.TrimSuffix(template, "/")
+	return nil
+}
+
+// apiPath formats a project API path template with the API key followed by args
+func (t *Tokbox) apiPath(template string, args ...interface{}) string {
+	if len(t.projectPath) > 0 {
+		template = t.projectPath + strings.TrimPrefix(template, apiProjectPath)
+	}
+	return fmt.Sprintf(template, append([]interface{}{t.apiKey}, args...)...)
+}
+
+// SetTimeout limits how long each API call may take, including reading the
+// response. A deadline of a context passed to a call still applies, the call
+// is aborted at whichever comes first. Downloads with Archive.Download are
+// not limited, as large archives can take longer. 0 disables the timeout.
+func (t *Tokbox) SetTimeout(d time.Duration) {
+	if d < 0 {
+		return
+	}
+	t.timeout = d
+}
+
+// httpClient returns the client API requests are sent with
+func (t *Tokbox) httpClient(ctx context.Context) *http.Client {
+	c := client(ctx)
+	c.Timeout = t.timeout
+	return c
+}
+
+// Logger receives the diagnostic output of the package, e.g. failed API
+// calls. It is satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// SetLogger sets where diagnostic output is written. The package writes none
+// unless a logger is set, pass nil to discard it again.
+func (t *Tokbox) SetLogger(logger Logger) {
+	t.logger = logger
+}
+
+// logf writes diagnostic output to the logger, if any
+func (t *Tokbox) logf(format string, v ...interface{}) {
+	if t.logger != nil {
+		t.logger.Printf(format, v...)
+	}
+}
+
+// endpoint returns the API host requests are sent to
+func (t *Tokbox) endpoint() string {
+	if t.betaURL == "" {
+		return apiHost
+	}
+	return t.betaURL
+}
+
+// do sends an authenticated request to the API. If body is not nil it is
+// sent JSON encoded. Responses with a non 2xx status code are returned as an
+// *APIError. The caller must close the body of the returned response.
+// A non-nil ctx is bound to the request, so its deadline and cancellation
+// abort the call.
+func (t *Tokbox) do(ctx context.Context, method, path string, body interface{}, opts ...RequestOption) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		jsonValue, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(jsonValue)
+	}
+
+	req, err := newRequest(ctx, method, t.endpoint()+path, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create jwt token
+	jwt, err := t.jwtToken()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("X-OPENTOK-AUTH", jwt)
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+	t.addExtraHeaders(req)
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	res, err := t.httpClient(ctx).Do(req)
+	if err != nil {
+		t.logf("tokbox: %s %s: %v", method, req.URL.Path, err)
+		return nil, err
+	}
+	if err = decompressBody(res); err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+		return nil, newAPIError(res)
+	}
+	return res, nil
+}
+
+// newRequest creates a request bound to ctx, so cancelling ctx or reaching
+// its deadline aborts it. A nil ctx leaves the request unbound.
+func newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return http.NewRequestWithContext(ctx, method, url, body)
+}
+
+// doJSON sends a request like do and decodes the JSON response into out,
+// unless out is nil
+func (t *Tokbox) doJSON(ctx context.Context, method, path string, body, out interface{}, opts ...RequestOption) error {
+	res, err := t.do(ctx, method, path, body, opts...)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// addExtraHeaders adds the headers set with SetExtraHeaders to req
+func (t *Tokbox) addExtraHeaders(req *http.Request) {
+	for key, values := range t.extraHeaders {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+}
+
+// decompressBody replaces the body of res with its decompressed content when
+// the transport left it gzip encoded, e.g. because the request set its own
+// Accept-Encoding header or the transport disables compression. Success and
+// error bodies are both read through it.
+func decompressBody(res *http.Response) error {
+	if res.Uncompressed || res.ContentLength == 0 || !strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		return nil
+	}
+
+	zr, err := gzip.NewReader(res.Body)
+	if err != nil {
+		return err
+	}
+	res.Body = gzipBody{zr, res.Body}
+	res.Header.Del("Content-Encoding")
+	res.ContentLength = -1
+	res.Uncompressed = true
+	return nil
+}
+
+// gzipBody is a decompressed response body
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+// Close closes both the gzip reader and the underlying body
+func (b gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
@@ -0,0 +1,234 @@
+package tokbox
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDoHeaders(t *testing.T) {
+	var got http.Header
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		w.Write([]byte(`{"id":"a1"}`))
+	})
+	tokbox.SetExtraHeaders(http.Header{"X-Client": {"dashboard"}, "X-Request-Id": {"global"}})
+
+	var archive Archive
+	err := tokbox.Do(nil, "GET", "/v2/project/123456/archive/a1", nil, &archive,
+		WithHeader("X-Request-Id", "retry-2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archive.ID != "a1" {
+		t.Fatalf("unexpected archive %+v", archive)
+	}
+	if got.Get("X-Client") != "dashboard" {
+		t.Fatalf("X-Client = %q, want the global header", got.Get("X-Client"))
+	}
+	if got.Get("X-Request-Id") != "retry-2" {
+		t.Fatalf("X-Request-Id = %q, want the per-call header", got.Get("X-Request-Id"))
+	}
+	if len(got.Get("X-OPENTOK-AUTH")) == 0 {
+		t.Fatal("missing X-OPENTOK-AUTH header")
+	}
+
+	// Per-call headers do not leak into other requests
+	if err = tokbox.Do(nil, "GET", "/v2/project/123456/archive/a1", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("X-Request-Id") != "global" {
+		t.Fatalf("X-Request-Id = %q, want the global header", got.Get("X-Request-Id"))
+	}
+	// Token auth replaces the project JWT
+	if err = tokbox.Do(nil, "GET", "/v2/project/123456/archive/a1", nil, nil, WithTokenAuth("T1==abc")); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("X-TB-TOKEN-AUTH") != "T1==abc" || len(got.Get("X-OPENTOK-AUTH")) != 0 {
+		t.Fatalf("unexpected auth headers %v", got)
+	}
+}
+
+func TestGzippedErrorBody(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(`{"code":409,"message":"Archive already started"}`))
+		zw.Close()
+
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusConflict)
+		w.Write(buf.Bytes())
+	})
+
+	// Setting Accept-Encoding stops the transport from decompressing transparently
+	err := tokbox.Do(nil, "POST", "/v2/project/123456/archive", nil, nil, WithHeader("Accept-Encoding", "gzip"))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an *APIError, got %v", err)
+	}
+	if apiErr.Message != "Archive already started" || apiErr.Code != "409" {
+		t.Fatalf("unexpected error %q", apiErr.Error())
+	}
+}
+
+func TestSetProjectPath(t *testing.T) {
+	var got string
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.URL.Path
+		w.Write([]byte(`{"id":"a1","streams":[]}`))
+	})
+
+	for _, template := range []string{"/api/projects", "/api/%s/%s", "/api/%d"} {
+		if err := tokbox.SetProjectPath(template); err == nil {
+			t.Errorf("expected an error for template %q", template)
+		}
+	}
+
+	if err := tokbox.SetProjectPath("/video/api/%s/"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokbox.ListArchiveStreams("a1"); err != nil {
+		t.Fatal(err)
+	}
+	if got != "/video/api/123456/archive/a1" {
+		t.Fatalf("path = %s", got)
+	}
+
+	if err := tokbox.SetProjectPath(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokbox.ListArchiveStreams("a1"); err != nil {
+		t.Fatal(err)
+	}
+	if got != "/v2/project/123456/archive/a1" {
+		t.Fatalf("path = %s", got)
+	}
+}
+
+func TestSetTimeout(t *testing.T) {
+	release := make(chan struct{})
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+	tokbox.SetTimeout(20 * time.Millisecond)
+
+	start := time.Now()
+	if _, err := tokbox.GetArchive("a1"); err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if _, err := tokbox.NewSession("", MediaRouter, ManualArchive); err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("calls took %v, the timeout was not applied", elapsed)
+	}
+}
+
+// logRecorder is a Logger keeping the lines written to it
+type logRecorder struct {
+	lines []string
+}
+
+func (l *logRecorder) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestSetLogger(t *testing.T) {
+	tokbox := New("123456", "secret")
+	// Nothing listens on port 1, so every call fails in the transport
+	tokbox.SetBetaURL("http://127.0.0.1:1")
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	if _, err := session.StopArchivingByID("a1"); err == nil {
+		t.Fatal("expected a transport error")
+	}
+
+	logger := &logRecorder{}
+	tokbox.SetLogger(logger)
+	if _, err := session.StopArchivingByID("a1"); err == nil {
+		t.Fatal("expected a transport error")
+	}
+	if _, err := tokbox.GetArchive("a1"); err == nil {
+		t.Fatal("expected a transport error")
+	}
+	if len(logger.lines) != 2 || !strings.Contains(logger.lines[0], "/archive/a1/stop") {
+		t.Fatalf("unexpected log %q", logger.lines)
+	}
+}
+
+func TestSetAPIHost(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte(`{"id":"a1","sessionId":"s1","status":"started"}`))
+	}))
+	defer srv.Close()
+
+	tokbox := New("123456", "secret")
+	tokbox.SetAPIHost(srv.URL + "/")
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	archive, err := session.StartArchiving(true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = archive.StopArchiving(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(paths, ",") != "/v2/project/123456/archive,/v2/project/123456/archive/a1/stop" {
+		t.Fatalf("unexpected requests %v", paths)
+	}
+
+	tokbox.SetAPIHost("api.staging.example.com")
+	if tokbox.endpoint() != "https://api.staging.example.com" {
+		t.Fatalf("endpoint = %s", tokbox.endpoint())
+	}
+	tokbox.SetAPIHost("")
+	if tokbox.endpoint() != apiHost {
+		t.Fatalf("endpoint = %s, want the default host", tokbox.endpoint())
+	}
+}
+
+func TestCancelledContext(t *testing.T) {
+	release := make(chan struct{})
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	start := time.Now()
+	if _, err := tokbox.GetArchive("a1", ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := session.StopArchivingByID("a1", ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := tokbox.NewSession("", MediaRouter, ManualArchive, ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("calls took %v, cancellation did not abort them", elapsed)
+	}
+}
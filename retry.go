@@ -0,0 +1,98 @@
+package tokbox
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// RetryPolicy controls how calls failing with a transient error are retried:
+// 429 Too Many Requests, 502 Bad Gateway, 503 Service Unavailable and 504
+// Gateway Timeout. Other errors are never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts of a call, including
+	// the first one. Values lower than 2 disable retries.
+	MaxAttempts int
+	// BaseBackoff is the wait before the first retry, it doubles with each
+	// further retry. A Retry-After header sent with a 429 takes precedence.
+	BaseBackoff time.Duration
+	// MaxBackoff caps the wait between two attempts, 30 seconds when 0. A
+	// call whose Retry-After asks to wait longer fails without retrying.
+	MaxBackoff time.Duration
+}
+
+// defaultMaxBackoff is the longest wait between two attempts when
+// RetryPolicy.MaxBackoff is not set
+const defaultMaxBackoff = 30 * time.Second
+
+// SetRetryPolicy sets how NewSession, NewSessions, GetOrCreateSession and
+// GetArchive retry transient failures. By default calls are not retried.
+func (t *Tokbox) SetRetryPolicy(policy RetryPolicy) {
+	t.retryPolicy = policy
+}
+
+// withRetry calls fn until it succeeds, fails with an error that is not
+// transient or the retry policy is exhausted. It stops waiting for the next
+// attempt when ctx is done.
+func (t *Tokbox) withRetry(ctx context.Context, fn func() error) error {
+	backoff := t.retryPolicy.BaseBackoff
+	maxBackoff := t.retryPolicy.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		var apiErr *APIError
+		if err == nil || attempt >= t.retryPolicy.MaxAttempts || !errors.As(err, &apiErr) || !apiErr.transient() {
+			return err
+		}
+
+		wait := min(backoff, maxBackoff)
+		if apiErr.StatusCode == http.StatusTooManyRequests && apiErr.retryAfter > 0 {
+			if apiErr.retryAfter > maxBackoff {
+				return err
+			}
+			wait = apiErr.retryAfter
+		}
+		backoff *= 2
+
+		if ctx == nil {
+			time.Sleep(wait)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// transient reports whether the call may succeed if it is retried
+func (e *APIError) transient() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parses a Retry-After header, given in seconds or as an
+// HTTP date. It returns 0 if the header is missing or invalid.
+func parseRetryAfter(header string) time.Duration {
+	if len(header) == 0 {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
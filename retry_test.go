@@ -0,0 +1,105 @@
+package tokbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var calls int
+	statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[calls%len(statuses)]
+		calls++
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`[{"session_id":"s1"}]`))
+	})
+
+	// No retries by default
+	if _, err := tokbox.NewSession("", MediaRouter, ManualArchive); err == nil || calls != 1 {
+		t.Fatalf("expected a single failed call, got %d calls and %v", calls, err)
+	}
+
+	calls = 0
+	tokbox.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond})
+	session, err := tokbox.NewSession("", MediaRouter, ManualArchive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.SessionID != "s1" || calls != 3 {
+		t.Fatalf("got session %+v after %d calls", session, calls)
+	}
+
+	calls = 0
+	statuses = []int{http.StatusBadRequest}
+	if _, err = tokbox.NewSession("", MediaRouter, ManualArchive); err == nil || calls != 1 {
+		t.Fatalf("client errors must not be retried, got %d calls", calls)
+	}
+}
+
+func TestRetryRespectsContext(t *testing.T) {
+	var calls int
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	tokbox.SetRetryPolicy(RetryPolicy{MaxAttempts: 5, BaseBackoff: time.Millisecond, MaxBackoff: 2 * time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := tokbox.GetArchive("a1", ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if calls != 1 || time.Since(start) > time.Second {
+		t.Fatalf("expected to give up waiting for Retry-After, got %d calls in %v", calls, time.Since(start))
+	}
+}
+
+func TestRetryMaxBackoff(t *testing.T) {
+	var calls int
+	retryAfter := "3600"
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if len(retryAfter) > 0 {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	tokbox.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Hour, MaxBackoff: 10 * time.Millisecond})
+
+	// Without a context nothing could cancel a long wait, so the call gives up
+	start := time.Now()
+	var apiErr *APIError
+	if _, err := tokbox.GetArchive("a1"); !errors.As(err, &apiErr) || calls != 1 {
+		t.Fatalf("expected the 429 after a single call, got %d calls and %v", calls, err)
+	}
+
+	// The exponential backoff is capped too
+	calls, retryAfter = 0, ""
+	if _, err := tokbox.GetArchive("a1"); !errors.As(err, &apiErr) || calls != 3 {
+		t.Fatalf("expected 3 calls, got %d calls and %v", calls, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("calls took %v, the backoff was not capped", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("3"); d != 3*time.Second {
+		t.Fatalf("got %v", d)
+	}
+	if d := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); d < 59*time.Minute {
+		t.Fatalf("got %v", d)
+	}
+	if d := parseRetryAfter("soon"); d != 0 {
+		t.Fatalf("got %v", d)
+	}
+}
@@ -0,0 +1,45 @@
+package tokbox
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+// maxSignalDataSize is the maximum size of the data of a signal, in bytes
+const maxSignalDataSize = 8 * 1024
+
+// signal is the body of a signal request
+type signal struct {
+	Type string `json:"type,omitempty"`
+	Data string `json:"data"`
+}
+
+// Signal sends a signal to every client connected to the session. data can
+// be at most 8 KB.
+func (s *Session) Signal(signalType, data string, ctx ...context.Context) error {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+	return s.sendSignal(ctx[0], s.tokbox.apiPath(apiSignalURL, s.SessionID), signalType, data)
+}
+
+// SignalConnection sends a signal to a single connection of the session.
+// data can be at most 8 KB.
+func (s *Session) SignalConnection(connectionID, signalType, data string, ctx ...context.Context) error {
+	if len(connectionID) == 0 {
+		return fmt.Errorf("connection ID must not be empty")
+	}
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+	return s.sendSignal(ctx[0], s.tokbox.apiPath(apiConnectionSignalURL, s.SessionID, connectionID), signalType, data)
+}
+
+// sendSignal checks the size of data and posts the signal to path
+func (s *Session) sendSignal(ctx context.Context, path, signalType, data string) error {
+	if len(data) > maxSignalDataSize {
+		return fmt.Errorf("signal data is %d bytes, it can be at most %d", len(data), maxSignalDataSize)
+	}
+	return wrapInvalidSession(s.tokbox.doJSON(ctx, "POST", path, signal{signalType, data}, nil))
+}
@@ -0,0 +1,44 @@
+package tokbox
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSignal(t *testing.T) {
+	var path string
+	var body signal
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("unexpected method %s", r.Method)
+		}
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	if err := session.Signal("chat", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v2/project/123456/session/s1/signal" || body.Type != "chat" || body.Data != "hello" {
+		t.Fatalf("unexpected signal %s %+v", path, body)
+	}
+
+	if err := session.SignalConnection("c1", "chat", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v2/project/123456/session/s1/connection/c1/signal" || body.Data != "hi" {
+		t.Fatalf("unexpected signal %s %+v", path, body)
+	}
+
+	path = ""
+	if err := session.Signal("chat", strings.Repeat("x", maxSignalDataSize+1)); err == nil {
+		t.Fatal("expected an error for data above 8 KB")
+	}
+	if len(path) != 0 {
+		t.Fatal("an oversized signal must not be sent")
+	}
+}
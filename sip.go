@@ -0,0 +1,129 @@
+package tokbox
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// SIPAuth holds the credentials used to authenticate with the SIP gateway
+type SIPAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// SIPOptions holds the settings of an outbound SIP call
+type SIPOptions struct {
+	// From is the number or address the call is placed from, e.g. "15551115555@example.com"
+	From string
+	// Headers are custom SIP headers sent with the INVITE. Their names must
+	// start with "X-", names starting with "X-OpenTok-" are reserved.
+	Headers map[string]string
+	Auth    *SIPAuth
+	// Secure encrypts the media of the call with SRTP and TLS
+	Secure bool
+	// Video includes video in the call, in addition to audio
+	Video bool
+}
+
+// SIPCall is a SIP call connected to a session
+type SIPCall struct {
+	ID           string `json:"id"`
+	ConnectionID string `json:"connectionId"`
+	StreamID     string `json:"streamId"`
+}
+
+// validate checks the SIP URI and the custom headers of a call
+func (opts SIPOptions) validate(sipURI string) error {
+	if !strings.HasPrefix(sipURI, "sip:") && !strings.HasPrefix(sipURI, "sips:") {
+		return fmt.Errorf("invalid SIP URI %q, it must start with sip: or sips:", sipURI)
+	}
+	for name := range opts.Headers {
+		if !strings.HasPrefix(strings.ToUpper(name), "X-") {
+			return fmt.Errorf("SIP header %q must start with X-", name)
+		}
+		if strings.HasPrefix(strings.ToUpper(name), "X-OPENTOK-") {
+			return fmt.Errorf("SIP header %q is reserved by OpenTok", name)
+		}
+	}
+	return nil
+}
+
+// Dial connects the session to a SIP endpoint, e.g. to bridge it with a phone
+// conference. token is a token of the session the SIP call connects with.
+func (s *Session) Dial(sipURI string, token string, opts SIPOptions, ctx ...context.Context) (*SIPCall, error) {
+	if err := opts.validate(sipURI); err != nil {
+		return nil, err
+	}
+	if len(token) == 0 {
+		return nil, fmt.Errorf("token must not be empty")
+	}
+
+	type sip struct {
+		URI     string            `json:"uri"`
+		From    string            `json:"from,omitempty"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Auth    *SIPAuth          `json:"auth,omitempty"`
+		Secure  bool              `json:"secure"`
+		Video   bool              `json:"video"`
+	}
+	values := struct {
+		SessionID string `json:"sessionId"`
+		Token     string `json:"token"`
+		SIP       sip    `json:"sip"`
+	}{
+		SessionID: s.SessionID,
+		Token:     token,
+		SIP:       sip{sipURI, opts.From, opts.Headers, opts.Auth, opts.Secure, opts.Video},
+	}
+
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	var call SIPCall
+	url := s.tokbox.apiPath(apiDialURL)
+	if err := s.tokbox.doJSON(ctx[0], "POST", url, values, &call); err != nil {
+		return nil, wrapInvalidSession(err)
+	}
+	return &call, nil
+}
+
+// PlayDTMF plays DTMF digits to every SIP call of the session. digits may
+// contain 0-9, *, #, and p or w for a 500 ms pause.
+func (s *Session) PlayDTMF(digits string, ctx ...context.Context) error {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+	return s.playDTMF(ctx[0], s.tokbox.apiPath(apiPlayDTMFURL, s.SessionID), digits)
+}
+
+// PlayDTMFToConnection plays DTMF digits to a single SIP call of the
+// session, identified by its connection ID. digits are checked like with PlayDTMF.
+func (s *Session) PlayDTMFToConnection(connectionID, digits string, ctx ...context.Context) error {
+	if len(connectionID) == 0 {
+		return fmt.Errorf("connection ID must not be empty")
+	}
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+	return s.playDTMF(ctx[0], s.tokbox.apiPath(apiConnectionDTMFURL, s.SessionID, connectionID), digits)
+}
+
+// playDTMF checks digits and posts them to path
+func (s *Session) playDTMF(ctx context.Context, path, digits string) error {
+	if len(digits) == 0 {
+		return fmt.Errorf("DTMF digits must not be empty")
+	}
+	if i := strings.IndexFunc(digits, func(r rune) bool {
+		return !strings.ContainsRune("0123456789*#pw", r)
+	}); i >= 0 {
+		return fmt.Errorf("invalid DTMF digit %q, only 0-9, *, #, p and w are allowed", digits[i])
+	}
+
+	values := struct {
+		Digits string `json:"digits"`
+	}{digits}
+	return s.tokbox.doJSON(ctx, "POST", path, values, nil)
+}
@@ -0,0 +1,85 @@
+package tokbox
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestDial(t *testing.T) {
+	var body map[string]interface{}
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v2/project/123456/dial" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"id":"call1","connectionId":"c1","streamId":"st1"}`))
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	opts := SIPOptions{
+		From:    "15551115555@example.com",
+		Headers: map[string]string{"X-Conference-Id": "42"},
+		Auth:    &SIPAuth{Username: "bob", Password: "secret"},
+		Secure:  true,
+	}
+	call, err := session.Dial("sip:conference@example.com", "T1==abc", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.ID != "call1" || call.ConnectionID != "c1" || call.StreamID != "st1" {
+		t.Fatalf("unexpected call %+v", call)
+	}
+
+	sip, _ := body["sip"].(map[string]interface{})
+	auth, _ := sip["auth"].(map[string]interface{})
+	if body["sessionId"] != "s1" || body["token"] != "T1==abc" || sip["uri"] != "sip:conference@example.com" ||
+		sip["secure"] != true || sip["video"] != false || auth["username"] != "bob" {
+		t.Fatalf("unexpected body %v", body)
+	}
+
+	invalid := []SIPOptions{
+		{Headers: map[string]string{"Conference-Id": "42"}},
+		{Headers: map[string]string{"X-OpenTok-Session": "s2"}},
+	}
+	for _, opts := range invalid {
+		if _, err = session.Dial("sip:conference@example.com", "T1==abc", opts); err == nil {
+			t.Fatalf("expected an error for headers %v", opts.Headers)
+		}
+	}
+	if _, err = session.Dial("tel:+15551115555", "T1==abc", SIPOptions{}); err == nil {
+		t.Fatal("expected an error for a URI that is not a SIP URI")
+	}
+}
+
+func TestPlayDTMF(t *testing.T) {
+	var path, digits string
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Digits string `json:"digits"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		path, digits = r.URL.Path, body.Digits
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	if err := session.PlayDTMF("1234#"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v2/project/123456/session/s1/play-dtmf" || digits != "1234#" {
+		t.Fatalf("unexpected request %s %q", path, digits)
+	}
+
+	if err := session.PlayDTMFToConnection("c1", "9pw*"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v2/project/123456/session/s1/connection/c1/play-dtmf" || digits != "9pw*" {
+		t.Fatalf("unexpected request %s %q", path, digits)
+	}
+
+	for _, invalid := range []string{"", "12a", "1 2"} {
+		if err := session.PlayDTMF(invalid); err == nil {
+			t.Fatalf("expected an error for digits %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package tokbox
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+)
+
+// Stream is a stream published to a session
+type Stream struct {
+	ID              string   `json:"id"`
+	VideoType       string   `json:"videoType"` // "camera", "screen" or "custom"
+	Name            string   `json:"name"`
+	LayoutClassList []string `json:"layoutClassList"`
+}
+
+// ListStreams returns the streams currently published to the session
+func (s *Session) ListStreams(ctx ...context.Context) ([]Stream, error) {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	var response struct {
+		Items []Stream `json:"items"`
+	}
+	url := s.tokbox.apiPath(apiListStreamsURL, s.SessionID)
+	if err := s.tokbox.doJSON(ctx[0], "GET", url, nil, &response); err != nil {
+		return nil, err
+	}
+	if response.Items == nil {
+		response.Items = []Stream{}
+	}
+	return response.Items, nil
+}
+
+// GetStream returns a stream published to the session
+func (s *Session) GetStream(streamID string, ctx ...context.Context) (*Stream, error) {
+	if len(streamID) == 0 {
+		return nil, fmt.Errorf("stream ID must not be empty")
+	}
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	var stream Stream
+	url := s.tokbox.apiPath(apiGetStreamURL, s.SessionID, streamID)
+	if err := s.tokbox.doJSON(ctx[0], "GET", url, nil, &stream); err != nil {
+		return nil, err
+	}
+	return &stream, nil
+}
+
+// StreamClassItem assigns layout classes to a stream of a session
+type StreamClassItem struct {
+	ID              string   `json:"id"`
+	LayoutClassList []string `json:"layoutClassList"`
+}
+
+// SetStreamClassLists sets the layout classes of streams of the session. The
+// classes apply to every composed archive and broadcast of the session, e.g.
+// with the bestFit layout, streams with the "focus" class get prominence.
+func (s *Session) SetStreamClassLists(items []StreamClassItem, ctx ...context.Context) error {
+	for i, item := range items {
+		if len(item.ID) == 0 {
+			return fmt.Errorf("stream class item %d has no stream ID", i)
+		}
+	}
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	values := struct {
+		Items []StreamClassItem `json:"items"`
+	}{items}
+
+	url := s.tokbox.apiPath(apiListStreamsURL, s.SessionID)
+	return s.tokbox.doJSON(ctx[0], "PUT", url, values, nil)
+}
+
+// MuteStream mutes the audio of a stream of the session. The publisher of
+// the stream can unmute it.
+func (s *Session) MuteStream(streamID string, ctx ...context.Context) error {
+	if len(streamID) == 0 {
+		return fmt.Errorf("stream ID must not be empty")
+	}
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	url := s.tokbox.apiPath(apiMuteStreamURL, s.SessionID, streamID)
+	return s.tokbox.doJSON(ctx[0], "POST", url, nil, nil)
+}
+
+// MuteAll mutes the audio of every stream of the session except
+// excludedStreamIDs. While active is true, streams published later are muted
+// too; call it with active set to false to stop muting new streams.
+func (s *Session) MuteAll(excludedStreamIDs []string, active bool, ctx ...context.Context) error {
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+
+	values := struct {
+		Active          bool     `json:"active"`
+		ExcludedStreams []string `json:"excludedStreams,omitempty"`
+	}{active, excludedStreamIDs}
+
+	url := s.tokbox.apiPath(apiMuteSessionURL, s.SessionID)
+	return s.tokbox.doJSON(ctx[0], "POST", url, values, nil)
+}
@@ -0,0 +1,136 @@
+package tokbox
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// classListServer records the class lists set on the streams of session s1
+func classListServer(t *testing.T, got *[]StreamClassItem) *Tokbox {
+	return newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/v2/project/123456/session/s1/stream" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		var body struct {
+			Items []StreamClassItem `json:"items"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		*got = body.Items
+	})
+}
+
+func TestSetStreamClassListsArchive(t *testing.T) {
+	var got []StreamClassItem
+	tokbox := classListServer(t, &got)
+	archive := &Archive{ID: "a1", S: &Session{SessionID: "s1", tokbox: tokbox}}
+
+	items := []StreamClassItem{{ID: "st1", LayoutClassList: []string{"focus"}}, {ID: "st2", LayoutClassList: []string{}}}
+	if err := archive.S.SetStreamClassLists(items); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "st1" || got[0].LayoutClassList[0] != "focus" || len(got[1].LayoutClassList) != 0 {
+		t.Fatalf("unexpected items %+v", got)
+	}
+}
+
+func TestSetStreamClassListsBroadcast(t *testing.T) {
+	var got []StreamClassItem
+	tokbox := classListServer(t, &got)
+	broadcast := &Broadcast{ID: "b1", S: &Session{SessionID: "s1", tokbox: tokbox}}
+
+	// Give the speaker prominence in a bestFit broadcast
+	items := []StreamClassItem{{ID: "speaker", LayoutClassList: []string{"focus"}}}
+	if err := broadcast.S.SetStreamClassLists(items); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "speaker" || got[0].LayoutClassList[0] != "focus" {
+		t.Fatalf("unexpected items %+v", got)
+	}
+}
+
+func TestSetStreamClassListsValidation(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":400,"message":"invalid stream"}`))
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	items := []StreamClassItem{{ID: "st1"}, {LayoutClassList: []string{"focus"}}}
+	if err := session.SetStreamClassLists(items); err == nil || !strings.Contains(err.Error(), "no stream ID") {
+		t.Fatalf("expected an error for an item without stream ID, got %v", err)
+	}
+
+	err := session.SetStreamClassLists(items[:1])
+	if err == nil || !strings.Contains(err.Error(), "invalid stream") {
+		t.Fatalf("expected the server error message, got %v", err)
+	}
+}
+
+func TestMute(t *testing.T) {
+	var path string
+	var body map[string]interface{}
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("unexpected method %s", r.Method)
+		}
+		path = r.URL.Path
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	if err := session.MuteStream("st1"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v2/project/123456/session/s1/stream/st1/mute" {
+		t.Fatalf("unexpected path %s", path)
+	}
+
+	if err := session.MuteAll(nil, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := body["excludedStreams"]; ok || body["active"] != true || path != "/v2/project/123456/session/s1/mute" {
+		t.Fatalf("unexpected request %s %v", path, body)
+	}
+
+	if err := session.MuteAll([]string{"st1"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if excluded, ok := body["excludedStreams"].([]interface{}); !ok || len(excluded) != 1 || body["active"] != false {
+		t.Fatalf("unexpected body %v", body)
+	}
+}
+
+func TestListStreams(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/project/123456/session/s1/stream":
+			w.Write([]byte(`{"count":2,"items":[
+				{"id":"st1","videoType":"camera","name":"bob","layoutClassList":["focus"]},
+				{"id":"st2","videoType":"screen","name":"","layoutClassList":[]}]}`))
+		case "/v2/project/123456/session/s1/stream/st1":
+			w.Write([]byte(`{"id":"st1","videoType":"camera","name":"bob","layoutClassList":["focus"]}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	streams, err := session.ListStreams()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 2 || streams[1].VideoType != "screen" || streams[0].LayoutClassList[0] != "focus" {
+		t.Fatalf("unexpected streams %+v", streams)
+	}
+
+	stream, err := session.GetStream("st1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stream.ID != "st1" || stream.Name != "bob" {
+		t.Fatalf("unexpected stream %+v", stream)
+	}
+}
@@ -2,7 +2,6 @@ package tokbox
 
 import (
 	"bytes"
-	"io"
 	"net/http"
 	"net/url"
 
@@ -10,12 +9,17 @@ import (
 	"encoding/json"
 
 	"crypto/hmac"
+	crand "crypto/rand"
 	"crypto/sha1"
+	"encoding/hex"
 
+	"errors"
 	"fmt"
 	"math/rand"
+	"reflect"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"sync"
 
@@ -26,10 +30,44 @@ import (
 )
 
 const (
-	apiHost              = "https://api.opentok.com"
-	apiSession           = "/session/create"
-	apiStartArchivingURL = "/v2/project/%s/archive"
-	apiStopArchivingURL  = "/v2/project/%s/archive/%s/stop"
+	apiHost                = "https://api.opentok.com"
+	apiSession             = "/session/create"
+	apiProjectPath         = "/v2/project/%s" // Prefix of all the paths below
+	apiStartArchivingURL   = "/v2/project/%s/archive"
+	apiStopArchivingURL    = "/v2/project/%s/archive/%s/stop"
+	apiListArchivesURL     = "/v2/project/%s/archive"
+	apiGetArchiveURL       = "/v2/project/%s/archive/%s"
+	apiDeleteArchiveURL    = "/v2/project/%s/archive/%s"
+	apiArchiveLayoutURL    = "/v2/project/%s/archive/%s/layout"
+	apiArchiveStreamsURL   = "/v2/project/%s/archive/%s/streams"
+	apiStartBroadcastURL   = "/v2/project/%s/broadcast"
+	apiGetBroadcastURL     = "/v2/project/%s/broadcast/%s"
+	apiListBroadcastsURL   = "/v2/project/%s/broadcast"
+	apiStopBroadcastURL    = "/v2/project/%s/broadcast/%s/stop"
+	apiBroadcastStreamsURL = "/v2/project/%s/broadcast/%s/streams"
+	apiBroadcastLayoutURL  = "/v2/project/%s/broadcast/%s/layout"
+	apiConnectionURL       = "/v2/project/%s/session/%s/connection/%s"
+	apiListStreamsURL      = "/v2/project/%s/session/%s/stream"
+	apiSignalURL           = "/v2/project/%s/session/%s/signal"
+	apiConnectionSignalURL = "/v2/project/%s/session/%s/connection/%s/signal"
+	apiMuteStreamURL       = "/v2/project/%s/session/%s/stream/%s/mute"
+	apiMuteSessionURL      = "/v2/project/%s/session/%s/mute"
+	apiGetStreamURL        = "/v2/project/%s/session/%s/stream/%s"
+	apiDialURL             = "/v2/project/%s/dial"
+	apiPlayDTMFURL         = "/v2/project/%s/session/%s/play-dtmf"
+	apiConnectionDTMFURL   = "/v2/project/%s/session/%s/connection/%s/play-dtmf"
+	apiStartRenderURL      = "/v2/project/%s/render"
+	apiRenderURL           = "/v2/project/%s/render/%s"
+	apiListRendersURL      = "/v2/project/%s/render"
+	apiConnectAudioURL     = "/v2/project/%s/connect"
+
+	// jwtTTL is the default lifetime of the JWT used to authenticate API requests, in seconds.
+	// NB: The maximum allowed expiration time range is 5 minutes.
+	jwtTTL int64 = 3 * 60
+	// maxJWTTTL is the maximum lifetime of the JWT accepted by OpenTok, in seconds
+	maxJWTTTL int64 = 5 * 60
+	// jwtRefreshMargin is how long before it expires the cached JWT is replaced, in seconds
+	jwtRefreshMargin int64 = 30
 )
 
 // MediaMode is the mode of media
@@ -69,46 +107,447 @@ const (
 	Moderator = "moderator"
 )
 
+// Valid reports whether r is one of the roles known to OpenTok
+func (r Role) Valid() bool {
+	switch r {
+	case Publisher, Subscriber, Moderator:
+		return true
+	}
+	return false
+}
+
+// IssuerType is the issuer type ("ist" claim) of the JWT used to authenticate API requests
+type IssuerType string
+
+const (
+	// ProjectIssuer Requests are authenticated as a project (default option).
+	ProjectIssuer IssuerType = "project"
+	// AccountIssuer Requests are authenticated as an account.
+	AccountIssuer IssuerType = "account"
+)
+
+// defaultConcurrency is the default number of API calls a Tokbox instance
+// issues in parallel for batch operations
+const defaultConcurrency = 10
+
+const (
+	// defaultNonceLength is the default number of random bytes in a token nonce
+	defaultNonceLength = 16
+	// minNonceLength is the smallest nonce length accepted by SetNonceLength
+	minNonceLength = 8
+)
+
 // Tokbox is the main struct to be used for API
 type Tokbox struct {
 	apiKey        string
 	partnerSecret string
 	betaURL       string //Endpoint for Beta Programs
+	concurrency   int    //Maximum number of parallel API calls in batch operations
+	ist           IssuerType
+	allowedRoles  map[Role]bool //Roles tokens may be minted for, nil allows all known roles
+
+	connectionDataValidator func(string) error
+	extraHeaders            http.Header   //Headers added to every API request
+	nonceLength             int           //Number of random bytes in a token nonce
+	projectPath             string        //Replaces apiProjectPath, for OpenTok-compatible servers
+	jwtTTL                  int64         //Lifetime of the API JWT in seconds
+	timeout                 time.Duration //Limit of each API call, 0 for none
+	logger                  Logger        //Receives diagnostic output, nil discards it
+	retryPolicy             RetryPolicy   //Retries of transient failures, none by default
+
+	jwtLock sync.Mutex
+	jwt     string //Cached API JWT, reused until it is about to expire
+	jwtExp  int64  //Expiration of the cached JWT
+}
+
+// SessionOptions holds the settings used to create a session
+type SessionOptions struct {
+	// Location is an IP address used as a location hint. Leave empty to let OpenTok decide.
+	Location    string
+	MediaMode   MediaMode
+	ArchiveMode ArchiveMode
+	// E2EE enables end-to-end encryption. It requires MediaMode to be set to
+	// MediaRouter explicitly and cannot be combined with AlwaysArchive.
+	E2EE bool
+	// ArchiveName and ArchiveResolution are the name and resolution of the
+	// archives OpenTok starts automatically. They require AlwaysArchive.
+	ArchiveName       string
+	ArchiveResolution Resolution
+	// ExtraParams are added as is to the create request, e.g. to use a
+	// parameter newer than this package. They are not validated.
+	ExtraParams url.Values
+}
+
+// validate checks that the options can be combined
+func (opts SessionOptions) validate() error {
+	if opts.E2EE {
+		if opts.MediaMode != MediaRouter {
+			return fmt.Errorf("end-to-end encrypted sessions must use the MediaRouter media mode, got %q", opts.MediaMode)
+		}
+		if opts.ArchiveMode == AlwaysArchive {
+			return fmt.Errorf("end-to-end encrypted sessions can not be archived")
+		}
+	}
+	if len(opts.ArchiveName) > 0 || len(opts.ArchiveResolution) > 0 {
+		if opts.ArchiveMode != AlwaysArchive {
+			return fmt.Errorf("archive name and resolution require the AlwaysArchive archive mode")
+		}
+	}
+	return validateResolution(opts.ArchiveResolution, ComposedArchive)
 }
 
 // Session tokbox session
 type Session struct {
-	SessionID      string  `json:"session_id"`
-	ProjectID      string  `json:"project_id"`
-	PartnerID      string  `json:"partner_id"`
-	CreateDt       string  `json:"create_dt"`
-	SessionStatus  string  `json:"session_status"`
-	MediaServerURL string  `json:"media_server_url"`
-	T              *Tokbox `json:"-"`
+	SessionID      string `json:"session_id"`
+	ProjectID      string `json:"project_id"`
+	PartnerID      string `json:"partner_id"`
+	CreateDt       string `json:"create_dt"`
+	SessionStatus  string `json:"session_status"`
+	MediaServerURL string `json:"media_server_url"`
+	E2EE           bool   `json:"e2ee"`
+	// RawExtra holds the keys of the create response this package does not
+	// know yet, e.g. fields added by OpenTok after this release
+	RawExtra map[string]json.RawMessage `json:"-"`
+	tokbox   *Tokbox
+	// created is set on sessions decoded from a create response, the only
+	// place OpenTok reports the media server URL
+	created bool
+}
+
+// ResolvedMediaMode returns the media mode OpenTok actually created the
+// session with, derived from the create response: routed sessions get a
+// media server URL, relayed (P2P) sessions do not. It is only meaningful
+// for sessions returned by NewSession, not for ones from SessionFromID.
+func (s *Session) ResolvedMediaMode() MediaMode {
+	if len(s.MediaServerURL) == 0 {
+		return P2P
+	}
+	return MediaRouter
+}
+
+// ParticipantCount returns the number of participants publishing to the
+// session, from a single call listing its streams. OpenTok does not list
+// connections, so participants that only subscribe are not counted, and
+// screen-sharing streams are not counted as extra participants.
+// On Google App Engine ctx must be an App Engine context.
+func (s *Session) ParticipantCount(ctx context.Context) (int, error) {
+	streams, err := s.ListStreams(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, stream := range streams {
+		if stream.VideoType != "screen" {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Equal reports whether s and other hold the same session data. Unlike
+// comparing the structs, it ignores which Tokbox instance they belong to.
+func (s *Session) Equal(other *Session) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	a, b := *s, *other
+	a.tokbox, b.tokbox = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+// Client returns the Tokbox instance the session belongs to. Use it to make
+// project-wide calls when only the session is at hand.
+func (s *Session) Client() *Tokbox {
+	return s.tokbox
+}
+
+// IsE2EE reports whether OpenTok created the session with end-to-end
+// encryption enabled. It reflects the create response, not the requested options.
+func (s *Session) IsE2EE() bool {
+	return s.E2EE
+}
+
+// e2eeKeyLength is the number of random bytes in a key made by GenerateE2EEKey
+const e2eeKeyLength = 32
+
+// GenerateE2EEKey returns a random base64 encoded secret to be used as the
+// shared encryption secret of an E2EE session. OpenTok never sees the
+// secret, the app distributes it to the clients itself, e.g. in the
+// connection data of their tokens.
+func GenerateE2EEKey() (string, error) {
+	key := make([]byte, e2eeKeyLength)
+	if _, err := crand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
 }
 
 // Archive struct represents archive create response
 type Archive struct {
-	CreatedAt  int      `json:"createdAt"`
-	Duration   int      `json:"duration"`
-	HasAudio   bool     `json:"hasAudio"`
-	HasVideo   bool     `json:"hasVideo"`
-	ID         string   `json:"id"`
-	Name       string   `json:"name"`
-	OutputMode string   `json:"outputMode"`
-	ProjectID  int      `json:"projectId"`
-	Reason     string   `json:"reason"`
-	Resolution string   `json:"resolution"`
-	SessionID  string   `json:"sessionId"`
-	Size       int      `json:"side"`
-	Status     string   `json:"status"`
-	URL        string   `json:"url"`
-	S          *Session `json:"-"`
-}
-
-// New creates a new tokbox instance
+	CreatedAt  int              `json:"createdAt"`
+	Duration   int              `json:"duration"`
+	HasAudio   bool             `json:"hasAudio"`
+	HasVideo   bool             `json:"hasVideo"`
+	ID         string           `json:"id"`
+	Name       string           `json:"name"`
+	OutputMode string           `json:"outputMode"`
+	ProjectID  int              `json:"projectId"`
+	Reason     string           `json:"reason"`
+	Resolution string           `json:"resolution"`
+	SessionID  string           `json:"sessionId"`
+	Sha256Sum  string           `json:"sha256sum"` // Checksum of the archive file, once uploaded
+	Size       int              `json:"size"`
+	Status     string           `json:"status"`
+	URL        string           `json:"url"`
+	Streams    []IncludedStream `json:"streams"`
+	StreamMode StreamMode       `json:"streamMode"`
+	// HasTranscription is set when a transcription was requested at start
+	HasTranscription bool                  `json:"hasTranscription"`
+	Transcription    *ArchiveTranscription `json:"transcription"`
+	// RawExtra holds the keys of the response this package does not know yet
+	RawExtra map[string]json.RawMessage `json:"-"`
+	S        *Session                   `json:"-"`
+}
+
+// ArchiveTranscription is the transcription of the audio of an archive
+type ArchiveTranscription struct {
+	Status              string `json:"status"` // "requested", "started", "available" or "failed"
+	URL                 string `json:"url"`    // Download URL, once available
+	Reason              string `json:"reason"` // Why the transcription failed, if it did
+	PrimaryLanguageCode string `json:"primaryLanguageCode"`
+	HasSummary          bool   `json:"hasSummary"`
+}
+
+// IncludedStream is a stream included in an archive or broadcast that uses
+// the manual stream mode, with the tracks that are being recorded
+type IncludedStream struct {
+	StreamID string `json:"streamId"`
+	HasAudio bool   `json:"hasAudio"`
+	HasVideo bool   `json:"hasVideo"`
+}
+
+// New creates a new tokbox instance. It does not check the credentials, use
+// NewWithError to catch a misconfigured API key or secret early.
 func New(apikey, partnerSecret string) *Tokbox {
-	return &Tokbox{apikey, partnerSecret, ""}
+	return &Tokbox{
+		apiKey:        apikey,
+		partnerSecret: partnerSecret,
+		concurrency:   defaultConcurrency,
+		ist:           ProjectIssuer,
+		nonceLength:   defaultNonceLength,
+		jwtTTL:        jwtTTL,
+	}
+}
+
+// NewWithError creates a new tokbox instance like New, but returns an error if
+// the API key or the secret is empty or the API key is not a numeric OpenTok
+// project ID
+func NewWithError(apikey, partnerSecret string) (*Tokbox, error) {
+	if len(apikey) == 0 {
+		return nil, fmt.Errorf("API key must not be empty")
+	}
+	if strings.IndexFunc(apikey, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return nil, fmt.Errorf("invalid API key %q, OpenTok API keys are numeric", apikey)
+	}
+	if len(partnerSecret) == 0 {
+		return nil, fmt.Errorf("secret must not be empty")
+	}
+	return New(apikey, partnerSecret), nil
+}
+
+// SetNonceLength sets the number of random bytes, read from crypto/rand, in
+// the nonce of each token (default 16). Lengths below 8 bytes would weaken
+// tokens and are rejected.
+func (t *Tokbox) SetNonceLength(n int) error {
+	if n < minNonceLength {
+		return fmt.Errorf("nonce length must be at least %d bytes, got %d", minNonceLength, n)
+	}
+	t.nonceLength = n
+	return nil
+}
+
+// nonce returns a hex encoded random token nonce
+func (t *Tokbox) nonce() (string, error) {
+	b := make([]byte, t.nonceLength)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// SetAllowedRoles restricts the roles Token accepts to roles. OpenTok does not
+// expose which roles a project permits, so use it to mirror the project
+// policy and fail client-side instead of minting tokens with fewer
+// privileges than expected. Calling it without roles allows all known roles.
+func (t *Tokbox) SetAllowedRoles(roles ...Role) error {
+	if len(roles) == 0 {
+		t.allowedRoles = nil
+		return nil
+	}
+
+	allowed := make(map[Role]bool, len(roles))
+	for _, role := range roles {
+		if !role.Valid() {
+			return fmt.Errorf("unknown role: %q", role)
+		}
+		allowed[role] = true
+	}
+	t.allowedRoles = allowed
+	return nil
+}
+
+// checkRole returns an error if tokens can not be minted for role. An empty
+// role stands for the OpenTok default, Publisher.
+func (t *Tokbox) checkRole(role Role) error {
+	if len(role) == 0 {
+		role = Publisher
+	}
+	if !role.Valid() {
+		return fmt.Errorf("unknown role: %q", role)
+	}
+	if t.allowedRoles != nil && !t.allowedRoles[role] {
+		return fmt.Errorf("role %q is not allowed for this project", role)
+	}
+	return nil
+}
+
+// SetConnectionDataValidator registers a function Token calls with the
+// connection data before signing. If it returns an error, no token is minted
+// and the error is returned. Pass nil to remove the validator.
+func (t *Tokbox) SetConnectionDataValidator(validator func(connectionData string) error) {
+	t.connectionDataValidator = validator
+}
+
+// maxTokenTTL is the maximum lifetime of a token in seconds, 30 days
+const maxTokenTTL int64 = 30 * 24 * 60 * 60
+
+// checkTokenExpiration checks that a token minted at now and expiring at
+// expire expires in the future and within 30 days of the creation of the
+// session, as OpenTok requires. The session creation time is not always
+// known, e.g. for sessions from SessionFromID, the window then starts now.
+func (s *Session) checkTokenExpiration(expire, now time.Time) error {
+	if !expire.After(now) {
+		return fmt.Errorf("token expire time is not in the future")
+	}
+	if limit := s.maxTokenTTL(now); expire.Unix()-now.Unix() > limit {
+		return fmt.Errorf("token expires in %d seconds, OpenTok accepts at most %d (30 days after the session was created)", expire.Unix()-now.Unix(), limit)
+	}
+	return nil
+}
+
+// maxTokenTTL returns the longest lifetime in seconds of a token minted at
+// now: 30 days, minus the age of the session when its creation time is known
+func (s *Session) maxTokenTTL(now time.Time) int64 {
+	created, ok := s.createdAt()
+	if !ok || !created.Before(now) {
+		return maxTokenTTL
+	}
+	return maxTokenTTL - (now.Unix() - created.Unix())
+}
+
+// createDtZones maps the zone abbreviations OpenTok sends in CreateDt to
+// their offsets in hours. time.Parse only resolves the abbreviation of the
+// local zone and gives the others a zero offset.
+var createDtZones = map[string]int{
+	"PST": -8, "PDT": -7,
+	"MST": -7, "MDT": -6,
+	"CST": -6, "CDT": -5,
+	"EST": -5, "EDT": -4,
+	"UTC": 0, "GMT": 0,
+}
+
+// maxZoneOffset is the largest offset east of UTC of any time zone
+const maxZoneOffset = 14 * time.Hour
+
+// createdAt parses CreateDt, which OpenTok sends like "Mon Mar 17 00:41:31 PDT 2014".
+// When the zone abbreviation is unknown the earliest instant the wall clock
+// can stand for is returned, so the 30 days window is never overestimated.
+func (s *Session) createdAt() (time.Time, bool) {
+	if len(s.CreateDt) == 0 {
+		return time.Time{}, false
+	}
+	if created, err := time.Parse(time.UnixDate, s.CreateDt); err == nil {
+		wall := time.Date(created.Year(), created.Month(), created.Day(),
+			created.Hour(), created.Minute(), created.Second(), 0, time.UTC)
+		zone, _ := created.Zone()
+		if hours, ok := createDtZones[zone]; ok {
+			return wall.Add(-time.Duration(hours) * time.Hour), true
+		}
+		return wall.Add(-maxZoneOffset), true
+	}
+	if created, err := time.Parse(time.RFC3339, s.CreateDt); err == nil {
+		return created, true
+	}
+	return time.Time{}, false
+}
+
+// maxConnectionDataLength is the maximum number of characters of connection data
+const maxConnectionDataLength = 1000
+
+// checkConnectionData checks the length of connection data, counted before
+// it is escaped, and runs the validator set with SetConnectionDataValidator
+func (t *Tokbox) checkConnectionData(data string) error {
+	if n := utf8.RuneCountInString(data); n > maxConnectionDataLength {
+		return fmt.Errorf("connection data is %d characters long, OpenTok accepts at most %d", n, maxConnectionDataLength)
+	}
+	if t.connectionDataValidator != nil {
+		return t.connectionDataValidator(data)
+	}
+	return nil
+}
+
+// SetIssuerType sets the issuer type of the JWT used to authenticate API
+// requests. Project-scoped endpoints require ProjectIssuer (default option),
+// account-scoped endpoints require AccountIssuer.
+func (t *Tokbox) SetIssuerType(ist IssuerType) error {
+	switch ist {
+	case ProjectIssuer, AccountIssuer:
+		t.jwtLock.Lock()
+		defer t.jwtLock.Unlock()
+		t.ist = ist
+		t.jwt = ""
+		return nil
+	}
+	return fmt.Errorf("unknown issuer type: %q", ist)
+}
+
+// SetBetaURL sends all API calls to url instead of the default OpenTok API
+// host, e.g. to use an endpoint of a Beta Program. An empty url restores the
+// default host.
+func (t *Tokbox) SetBetaURL(url string) {
+	t.betaURL = strings.TrimSuffix(url, "/")
+}
+
+// SetAPIHost sends all API calls to host instead of the default OpenTok API
+// host, e.g. to a staging environment or a local test server. host is a URL
+// such as "http://localhost:8080", https is assumed when it has no scheme.
+// An empty host restores the default host.
+func (t *Tokbox) SetAPIHost(host string) {
+	if len(host) > 0 && !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	t.SetBetaURL(host)
+}
+
+// SetConcurrency sets the maximum number of API calls issued in parallel by
+// batch operations such as NewSessions. Values lower than 1 are ignored.
+func (t *Tokbox) SetConcurrency(n int) {
+	if n < 1 {
+		return
+	}
+	t.concurrency = n
+}
+
+// SetJWTTTL sets the lifetime of the JWT used to authenticate API requests,
+// 3 minutes by default. OpenTok accepts at most 5 minutes. A lifetime that
+// does not expire after the JWT is issued or that exceeds the maximum makes
+// every API call fail before it is sent.
+func (t *Tokbox) SetJWTTTL(ttl time.Duration) {
+	t.jwtLock.Lock()
+	defer t.jwtLock.Unlock()
+	t.jwtTTL = int64(ttl / time.Second)
+	t.jwt = ""
 }
 
 func (t *Tokbox) jwtToken() (string, error) {
@@ -118,39 +557,185 @@ func (t *Tokbox) jwtToken() (string, error) {
 		jwt.StandardClaims
 	}
 
+	t.jwtLock.Lock()
+	defer t.jwtLock.Unlock()
+
+	// Compute iat once so that exp is always exactly jwtTTL seconds after it
+	iat := time.Now().UTC().Unix()
+	if len(t.jwt) > 0 && iat < t.jwtExp-jwtRefreshMargin {
+		return t.jwt, nil
+	}
+
+	exp := iat + t.jwtTTL
+	if exp <= iat {
+		return "", fmt.Errorf("JWT would expire at %d, not after it is issued at %d: invalid JWT TTL of %d seconds", exp, iat, t.jwtTTL)
+	}
+	if t.jwtTTL > maxJWTTTL {
+		return "", fmt.Errorf("invalid JWT TTL of %d seconds, OpenTok accepts at most %d", t.jwtTTL, maxJWTTTL)
+	}
+
 	claims := TokboxClaims{
-		"project",
+		string(t.ist),
 		jwt.StandardClaims{
 			Issuer:    t.apiKey,
-			IssuedAt:  time.Now().UTC().Unix(),
-			ExpiresAt: time.Now().UTC().Unix() + (2 * 24 * 60 * 60), // 2 hours; //NB: The maximum allowed expiration time range is 5 minutes.
+			IssuedAt:  iat,
+			ExpiresAt: exp,
 			Id:        uuid.NewString(),
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(t.partnerSecret))
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(t.partnerSecret))
+	if err != nil {
+		return "", err
+	}
+	t.jwt, t.jwtExp = token, exp
+	return token, nil
 }
 
 // NewSession Creates a new tokbox session or returns an error.
 // See README file for full documentation: https://github.com/aogz/tokbox
-// NOTE: ctx must be nil if *not* using Google App Engine
+// On Google App Engine ctx must be an App Engine context.
 func (t *Tokbox) NewSession(location string, mm MediaMode, am ArchiveMode, ctx ...context.Context) (*Session, error) {
-	params := url.Values{}
+	if len(ctx) == 0 {
+		ctx = append(ctx, nil)
+	}
+	return t.newSession(ctx[0], SessionOptions{Location: location, MediaMode: mm, ArchiveMode: am})
+}
 
-	if len(location) > 0 {
-		params.Add("location", location)
+// NewSessionContext works like NewSession with the context as the first
+// parameter. The request is aborted when ctx is cancelled or its deadline
+// passes. On Google App Engine ctx must be an App Engine context.
+func (t *Tokbox) NewSessionContext(ctx context.Context, location string, mm MediaMode, am ArchiveMode) (*Session, error) {
+	return t.NewSession(location, mm, am, ctx)
+}
+
+// NewSessions creates n sessions with the same options in parallel, issuing
+// at most SetConcurrency calls at a time. It aborts on the first error and
+// returns no sessions in that case.
+// On Google App Engine ctx must be an App Engine context.
+func (t *Tokbox) NewSessions(n int, opts SessionOptions, ctx context.Context) ([]*Session, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid number of sessions: %d", n)
 	}
 
-	params.Add("p2p.preference", string(mm))
-	params.Add("archiveMode", string(am))
+	sessions := make([]*Session, n)
+
+	var w sync.WaitGroup
+	var lock sync.Mutex
+	var firstErr error
+	sem := make(chan struct{}, t.concurrency)
+
+	for i := 0; i < n; i++ {
+		w.Add(1)
+		go func(i int) {
+			defer w.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			lock.Lock()
+			failed := firstErr != nil
+			lock.Unlock()
+			if failed {
+				return
+			}
+
+			s, err := t.newSession(ctx, opts)
+			if err != nil {
+				lock.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				lock.Unlock()
+				return
+			}
+			sessions[i] = s
+		}(i)
+	}
 
-	var endpoint string
-	if t.betaURL == "" {
-		endpoint = apiHost
-	} else {
-		endpoint = t.betaURL
+	w.Wait()
+	if firstErr != nil {
+		return nil, firstErr
 	}
-	req, err := http.NewRequest("POST", endpoint+apiSession, strings.NewReader(params.Encode()))
+	return sessions, nil
+}
+
+// SessionFromID returns a session created earlier, bound to t, so tokens can
+// be minted and archives, broadcasts and signals managed for it without
+// creating a new session. OpenTok can not fetch a session, so only the
+// SessionID of the returned session is set and it is not checked to exist.
+func (t *Tokbox) SessionFromID(sessionID string) *Session {
+	return &Session{SessionID: sessionID, tokbox: t}
+}
+
+// SessionStore maps external IDs, e.g. room IDs, to OpenTok session IDs.
+// It is implemented by the caller, typically on top of a database.
+type SessionStore interface {
+	Get(externalID string) (sessionID string, ok bool)
+	Put(externalID, sessionID string)
+}
+
+// GetOrCreateSession returns the session mapped to externalID in store. If
+// there is none, it creates a session with opts and stores the mapping.
+// Calls for the same externalID must be serialized by the caller, otherwise
+// each of them may create a session.
+// On Google App Engine ctx must be an App Engine context.
+func (t *Tokbox) GetOrCreateSession(ctx context.Context, store SessionStore, externalID string, opts SessionOptions) (*Session, error) {
+	if sessionID, ok := store.Get(externalID); ok {
+		return t.SessionFromID(sessionID), nil
+	}
+
+	s, err := t.newSession(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	store.Put(externalID, s.SessionID)
+	return s, nil
+}
+
+// newSession validates opts and creates a session, retrying transient
+// failures according to the retry policy
+func (t *Tokbox) newSession(ctx context.Context, opts SessionOptions) (*Session, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	var s *Session
+	err := t.withRetry(ctx, func() (err error) {
+		s, err = t.createSession(ctx, opts)
+		return err
+	})
+	return s, err
+}
+
+// createSession sends a single request to create a session
+func (t *Tokbox) createSession(ctx context.Context, opts SessionOptions) (*Session, error) {
+
+	params := url.Values{}
+
+	if len(opts.Location) > 0 {
+		params.Add("location", opts.Location)
+	}
+	if len(opts.MediaMode) > 0 {
+		params.Add("p2p.preference", string(opts.MediaMode))
+	}
+	if len(opts.ArchiveMode) > 0 {
+		params.Add("archiveMode", string(opts.ArchiveMode))
+	}
+	if opts.E2EE {
+		params.Add("e2ee", "true")
+	}
+	if len(opts.ArchiveName) > 0 {
+		params.Add("archiveName", opts.ArchiveName)
+	}
+	if len(opts.ArchiveResolution) > 0 {
+		params.Add("archiveResolution", string(opts.ArchiveResolution))
+	}
+	for key, values := range opts.ExtraParams {
+		for _, value := range values {
+			params.Add(key, value)
+		}
+	}
+
+	req, err := newRequest(ctx, "POST", t.endpoint()+apiSession, strings.NewReader(params.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -163,18 +748,21 @@ func (t *Tokbox) NewSession(location string, mm MediaMode, am ArchiveMode, ctx .
 
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("X-OPENTOK-AUTH", jwt)
+	t.addExtraHeaders(req)
 
-	if len(ctx) == 0 {
-		ctx = append(ctx, nil)
-	}
-	res, err := client(ctx[0]).Do(req)
+	res, err := t.httpClient(ctx).Do(req)
 	if err != nil {
+		t.logf("tokbox: POST %s: %v", req.URL.Path, err)
+		return nil, err
+	}
+	if err = decompressBody(res); err != nil {
+		res.Body.Close()
 		return nil, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
-		return nil, fmt.Errorf("Tokbox returns error code: %v", res.StatusCode)
+		return nil, newAPIError(res)
 	}
 
 	var s []Session
@@ -187,135 +775,193 @@ func (t *Tokbox) NewSession(location string, mm MediaMode, am ArchiveMode, ctx .
 	}
 
 	o := s[0]
-	o.T = t
+	o.tokbox = t
+	o.created = true
 	return &o, nil
 }
 
 // StartArchiving starts archiving session
 func (s *Session) StartArchiving(archiveVideo bool, archiveAudio bool, ctx ...context.Context) (*Archive, error) {
-	var archive Archive
+	return s.StartArchivingWithOptions(ArchiveOptions{HasAudio: archiveAudio, HasVideo: archiveVideo}, ctx...)
+}
 
-	values := map[string]interface{}{
-		"sessionId": s.SessionID,
-		"hasAudio":  archiveAudio,
-		"hasVideo":  archiveVideo,
-	}
-	jsonValue, _ := json.Marshal(values)
+// StartArchivingContext works like StartArchivingWithOptions with the
+// context as the first parameter, the request is aborted when ctx is done
+func (s *Session) StartArchivingContext(ctx context.Context, opts ArchiveOptions) (*Archive, error) {
+	return s.StartArchivingWithOptions(opts, ctx)
+}
+
+// StopArchivingContext works like StopArchivingByID with the context as the
+// first parameter, the request is aborted when ctx is done
+func (s *Session) StopArchivingContext(ctx context.Context, archiveID string) (*Archive, error) {
+	return s.StopArchivingByID(archiveID, ctx)
+}
 
-	url := fmt.Sprintf(apiHost+apiStartArchivingURL, s.T.apiKey)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonValue))
+// StopArchiving stops current archive
+func (archive *Archive) StopArchiving(ctx ...context.Context) (*Archive, error) {
+	session, err := archive.session()
 	if err != nil {
 		return nil, err
 	}
+	return session.StopArchivingByID(archive.ID, ctx...)
+}
 
-	// Create jwt token
-	jwt, err := s.T.jwtToken()
-	if err != nil {
-		return nil, err
+// StopArchivingByID stops the archive with the given ID. Use it to stop an
+// archive of the session when the Archive returned by StartArchiving is gone.
+// Stopping is idempotent, so it is safe to retry: when OpenTok reports that
+// the archive is not started, the archive is fetched and returned without an
+// error if it is already stopped, uploaded or available.
+func (s *Session) StopArchivingByID(archiveID string, ctx ...context.Context) (*Archive, error) {
+	archive, err := s.stopArchiving(archiveID, ctx...)
+	if errors.Is(err, ErrArchiveNotStarted) {
+		current, getErr := s.GetArchive(archiveID, ctx...)
+		if getErr != nil {
+			return nil, err
+		}
+		switch current.Status {
+		case "stopped", "uploaded", "available":
+			return current, nil
+		}
 	}
+	return archive, err
+}
 
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("X-OPENTOK-AUTH", jwt)
+// stopArchiving sends the request to stop an archive
+func (s *Session) stopArchiving(archiveID string, ctx ...context.Context) (*Archive, error) {
+	var response Archive
 
 	if len(ctx) == 0 {
 		ctx = append(ctx, nil)
 	}
 
-	res, err := client(ctx[0]).Do(req)
-	if err != nil {
-		fmt.Println(err)
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != 200 {
-		bodyBytes, _ := io.ReadAll(res.Body)
-		stringResponse := string(bodyBytes)
-		return nil, fmt.Errorf("Tokbox returns error code: %v. Message: %s", res.StatusCode, stringResponse)
-	}
-
-	if err = json.NewDecoder(res.Body).Decode(&archive); err != nil {
-		return nil, err
-	}
-
-	archive.S = s
-	return &archive, nil
-}
-
-// StopArchiving stops current archive
-func (archive *Archive) StopArchiving(ctx ...context.Context) (*Archive, error) {
-	var response Archive
-
-	url := fmt.Sprintf(apiHost+apiStopArchivingURL, archive.S.T.apiKey, archive.ID)
-	req, err := http.NewRequest("POST", url, bytes.NewBufferString(""))
+	url := s.tokbox.endpoint() + s.tokbox.apiPath(apiStopArchivingURL, archiveID)
+	req, err := newRequest(ctx[0], "POST", url, bytes.NewBufferString(""))
 	if err != nil {
 		return nil, err
 	}
 
 	// Create jwt token
-	jwt, err := archive.S.T.jwtToken()
+	jwt, err := s.tokbox.jwtToken()
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("X-OPENTOK-AUTH", jwt)
+	s.tokbox.addExtraHeaders(req)
 
-	if len(ctx) == 0 {
-		ctx = append(ctx, nil)
-	}
-
-	res, err := client(ctx[0]).Do(req)
+	res, err := s.tokbox.httpClient(ctx[0]).Do(req)
 	if err != nil {
-		fmt.Println(err)
+		s.tokbox.logf("tokbox: POST %s: %v", req.URL.Path, err)
+		return nil, err
+	}
+	if err = decompressBody(res); err != nil {
+		res.Body.Close()
 		return nil, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
-		bodyBytes, _ := io.ReadAll(res.Body)
-		stringResponse := string(bodyBytes)
-		return nil, fmt.Errorf("Tokbox returns error code: %v. Message: %s", res.StatusCode, stringResponse)
+		return nil, stopArchiveErrors.wrap(newAPIError(res))
 	}
 
 	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
 		return nil, err
 	}
 
-	response.S = archive.S
+	response.S = s
 	return &response, nil
 }
 
+// TokenOptions holds the settings of a token minted with GenerateToken
+type TokenOptions struct {
+	Role Role
+	// Data is the connection data, readable by the other clients
+	Data string
+	// ExpireTime is when the token expires, the zero time means never
+	ExpireTime time.Time
+	// InitialLayoutClassList assigns layout classes to the streams the
+	// client publishes, so custom archive and broadcast layouts can place
+	// them from the start
+	InitialLayoutClassList []string
+}
+
+// GenerateToken creates a token with opts
+func (s *Session) GenerateToken(opts TokenOptions) (string, error) {
+	return s.generateToken(opts, time.Now())
+}
+
 // Token to crate json web token
 func (s *Session) Token(role Role, connectionData string, expiration int64) (string, error) {
-	now := time.Now().UTC().Unix()
+	return s.TokenWithLayoutClasses(role, connectionData, expiration, nil)
+}
 
-	dataStr := ""
-	dataStr += "session_id=" + url.QueryEscape(s.SessionID)
-	dataStr += "&create_time=" + url.QueryEscape(fmt.Sprintf("%d", now))
+// TokenWithLayoutClasses works like Token and assigns layoutClasses to the
+// streams the client publishes, so custom archive and broadcast layouts can
+// place them from the start. No classes are assigned when layoutClasses is empty.
+// An expiration of 0 mints a token that never expires, a negative expiration
+// is rejected.
+func (s *Session) TokenWithLayoutClasses(role Role, connectionData string, expiration int64, layoutClasses []string) (string, error) {
+	if expiration < 0 {
+		return "", fmt.Errorf("invalid token expiration %d, it must be 0 or positive", expiration)
+	}
+	// Share now with generateToken, so the token lasts exactly expiration seconds
+	now := time.Now()
+	opts := TokenOptions{Role: role, Data: connectionData, InitialLayoutClassList: layoutClasses}
 	if expiration > 0 {
-		dataStr += "&expire_time=" + url.QueryEscape(fmt.Sprintf("%d", now+expiration))
+		opts.ExpireTime = now.Add(time.Duration(expiration) * time.Second)
 	}
-	if len(role) > 0 {
-		dataStr += "&role=" + url.QueryEscape(string(role))
+	return s.generateToken(opts, now)
+}
+
+// generateToken mints a T1 token created at now
+func (s *Session) generateToken(opts TokenOptions, now time.Time) (string, error) {
+	if err := s.tokbox.checkRole(opts.Role); err != nil {
+		return "", err
 	}
-	if len(connectionData) > 0 {
-		dataStr += "&connection_data=" + url.QueryEscape(connectionData)
+	if err := s.tokbox.checkConnectionData(opts.Data); err != nil {
+		return "", err
+	}
+	if !opts.ExpireTime.IsZero() {
+		if err := s.checkTokenExpiration(opts.ExpireTime, now); err != nil {
+			return "", err
+		}
 	}
-	dataStr += "&nonce=" + url.QueryEscape(fmt.Sprintf("%d", rand.Intn(999999)))
 
-	h := hmac.New(sha1.New, []byte(s.T.partnerSecret))
-	n, err := h.Write([]byte(dataStr))
+	dataStr := ""
+	dataStr += "session_id=" + url.QueryEscape(s.SessionID)
+	dataStr += "&create_time=" + url.QueryEscape(fmt.Sprintf("%d", now.Unix()))
+	if !opts.ExpireTime.IsZero() {
+		dataStr += "&expire_time=" + url.QueryEscape(fmt.Sprintf("%d", opts.ExpireTime.Unix()))
+	}
+	if len(opts.Role) > 0 {
+		dataStr += "&role=" + url.QueryEscape(string(opts.Role))
+	}
+	if len(opts.Data) > 0 {
+		dataStr += "&connection_data=" + url.QueryEscape(opts.Data)
+	}
+	if len(opts.InitialLayoutClassList) > 0 {
+		dataStr += "&initial_layout_class_list=" + url.QueryEscape(strings.Join(opts.InitialLayoutClassList, " "))
+	}
+	nonce, err := s.tokbox.nonce()
 	if err != nil {
 		return "", err
 	}
-	if n != len(dataStr) {
-		return "", fmt.Errorf("hmac not enough bytes written %d != %d", n, len(dataStr))
+	dataStr += "&nonce=" + url.QueryEscape(nonce)
+
+	return encodeToken(s.tokbox.apiKey, s.tokbox.partnerSecret, dataStr)
+}
+
+// encodeToken signs the token data and encodes it in the T1 token format
+func encodeToken(apiKey, partnerSecret, dataStr string) (string, error) {
+	sig, err := tokenSignature(partnerSecret, dataStr)
+	if err != nil {
+		return "", err
 	}
 
 	preCoded := ""
-	preCoded += "partner_id=" + s.T.apiKey
-	preCoded += "&sig=" + fmt.Sprintf("%x:%s", h.Sum(nil), dataStr)
+	preCoded += "partner_id=" + apiKey
+	preCoded += "&sig=" + sig + ":" + dataStr
 
 	var buf bytes.Buffer
 	encoder := base64.NewEncoder(base64.StdEncoding, &buf)
@@ -324,39 +970,178 @@ func (s *Session) Token(role Role, connectionData string, expiration int64) (str
 	return fmt.Sprintf("T1==%s", buf.String()), nil
 }
 
-// Tokens ...
-func (s *Session) Tokens(n int, multithread bool, role Role, connectionData string, expiration int64) []string {
-	ret := []string{}
+// tokenSignature returns the signature of the token data, the HMAC-SHA1 of
+// dataStr keyed with the partner secret, encoded as lowercase hex
+func tokenSignature(partnerSecret, dataStr string) (string, error) {
+	h := hmac.New(sha1.New, []byte(partnerSecret))
+	n, err := h.Write([]byte(dataStr))
+	if err != nil {
+		return "", err
+	}
+	if n != len(dataStr) {
+		return "", fmt.Errorf("hmac not enough bytes written %d != %d", n, len(dataStr))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// TokenForUser creates a token whose connection data is the JSON envelope
+// {"uid": uid}, so the connection can be correlated back to the user the
+// token was minted for, e.g. with UIDFromConnectionData in callbacks.
+func (s *Session) TokenForUser(role Role, uid string, expiration int64) (string, error) {
+	if len(uid) == 0 {
+		return "", fmt.Errorf("uid must not be empty")
+	}
+	data, err := json.Marshal(connectionDataEnvelope{UID: uid})
+	if err != nil {
+		return "", err
+	}
+	return s.Token(role, string(data), expiration)
+}
+
+// connectionDataEnvelope is the connection data written by TokenForUser
+type connectionDataEnvelope struct {
+	UID string `json:"uid"`
+}
+
+// UIDFromConnectionData returns the uid embedded in connection data by
+// TokenForUser. ok is false if the data has no such envelope.
+func UIDFromConnectionData(connectionData string) (uid string, ok bool) {
+	var envelope connectionDataEnvelope
+	if err := json.Unmarshal([]byte(connectionData), &envelope); err != nil {
+		return "", false
+	}
+	return envelope.UID, len(envelope.UID) > 0
+}
+
+// SessionTokenSpec describes a token to mint with BatchTokens
+type SessionTokenSpec struct {
+	SessionID      string
+	Role           Role
+	ConnectionData string
+	Expiration     int64
+}
+
+// BatchTokens mints one token per spec, across any number of sessions. The
+// tokens are minted in parallel, at most SetConcurrency at once, and are
+// returned in the order of specs. If minting any token fails, the first error
+// is returned and no tokens are returned.
+func (t *Tokbox) BatchTokens(specs []SessionTokenSpec) ([]string, error) {
+	tokens := make([]string, len(specs))
+
+	var w sync.WaitGroup
+	var lock sync.Mutex
+	var firstErr error
+	sem := make(chan struct{}, t.concurrency)
+
+	for i := range specs {
+		w.Add(1)
+		go func(i int) {
+			defer w.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			spec := specs[i]
+			s := t.SessionFromID(spec.SessionID)
+			token, err := s.Token(spec.Role, spec.ConnectionData, spec.Expiration)
+			if err != nil {
+				lock.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("token for session %s: %w", spec.SessionID, err)
+				}
+				lock.Unlock()
+				return
+			}
+			tokens[i] = token
+		}(i)
+	}
+
+	w.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return tokens, nil
+}
+
+// Tokens generates n tokens with the same settings, see Token. The tokens
+// are returned in the order they were requested, also when they are
+// generated concurrently. If generating any token fails, the first error is
+// returned and no tokens are returned.
+func (s *Session) Tokens(n int, multithread bool, role Role, connectionData string, expiration int64) ([]string, error) {
+	return s.TokensWithJitter(n, multithread, role, connectionData, expiration, 0)
+}
+
+// TokensWithJitter works like Tokens but moves the expiration of each token
+// by a random amount of up to +/- jitter seconds, so that tokens minted
+// together do not all expire at the same instant. The jitter is capped so
+// that every token keeps an expiration of at least one second and none
+// expires later than OpenTok accepts. It has no effect on tokens without
+// expiration.
+func (s *Session) TokensWithJitter(n int, multithread bool, role Role, connectionData string, expiration int64, jitter int64) ([]string, error) {
+	if jitter > expiration-1 {
+		jitter = expiration - 1
+	}
+	// Keep jittered tokens within the lifetime OpenTok accepts
+	if limit := s.maxTokenTTL(time.Now()); jitter > limit-expiration {
+		jitter = limit - expiration
+	}
+	jittered := func() int64 {
+		if expiration <= 0 || jitter <= 0 {
+			return expiration
+		}
+		return expiration + rand.Int63n(2*jitter+1) - jitter
+	}
+
+	return mintTokens(n, multithread, func(int) (string, error) {
+		return s.Token(role, connectionData, jittered())
+	})
+}
+
+// mintTokens calls mint for each index from 0 to n-1, concurrently if
+// multithread is set, and returns the tokens in index order. If any call
+// fails, the first error is returned and no tokens are returned.
+func mintTokens(n int, multithread bool, mint func(i int) (string, error)) ([]string, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid number of tokens %d", n)
+	}
+	ret := make([]string, n)
 
 	if multithread {
+		// Each goroutine writes to its own index, so the tokens keep the
+		// order in which they were requested
 		var w sync.WaitGroup
 		var lock sync.Mutex
+		var firstErr error
 		w.Add(n)
 
 		for i := 0; i < n; i++ {
-			go func(role Role, connectionData string, expiration int64) {
-				a, e := s.Token(role, connectionData, expiration)
-				if e == nil {
+			go func(i int) {
+				defer w.Done()
+				a, e := mint(i)
+				if e != nil {
 					lock.Lock()
-					ret = append(ret, a)
+					if firstErr == nil {
+						firstErr = e
+					}
 					lock.Unlock()
+					return
 				}
-				w.Done()
-			}(role, connectionData, expiration)
-
+				ret[i] = a
+			}(i)
 		}
 
 		w.Wait()
-		return ret
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return ret, nil
 	}
 
 	for i := 0; i < n; i++ {
-
-		a, e := s.Token(role, connectionData, expiration)
-		if e == nil {
-			ret = append(ret, a)
+		a, e := mint(i)
+		if e != nil {
+			return nil, e
 		}
+		ret[i] = a
 	}
-	return ret
-
+	return ret, nil
 }
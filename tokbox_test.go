@@ -3,15 +3,37 @@ package tokbox
 //Adapted from https://github.com/cioc/tokbox
 
 import (
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
 )
 
 const key = ""
 const secret = ""
 
+// newTestTokbox returns a Tokbox whose API calls are served by handler
+func newTestTokbox(t *testing.T, handler http.HandlerFunc) *Tokbox {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	tokbox := New("123456", "secret")
+	tokbox.SetBetaURL(srv.URL)
+	return tokbox
+}
+
 func TestToken(t *testing.T) {
 	tokbox := New(key, secret)
 	session, err := tokbox.NewSession("", P2P, ManualArchive)
@@ -81,3 +103,607 @@ func TestStopArchiving(t *testing.T) {
 		}
 	}
 }
+
+func TestJWTTokenExpiration(t *testing.T) {
+	tokbox := New("123456", "secret")
+	token, err := tokbox.jwtToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.StandardClaims{}
+	_, err = jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.ExpiresAt <= claims.IssuedAt {
+		t.Fatalf("exp %d is not after iat %d", claims.ExpiresAt, claims.IssuedAt)
+	}
+	if claims.ExpiresAt-claims.IssuedAt != jwtTTL {
+		t.Fatalf("exp - iat = %d, want %d", claims.ExpiresAt-claims.IssuedAt, jwtTTL)
+	}
+	if claims.ExpiresAt-claims.IssuedAt > 300 {
+		t.Fatalf("exp - iat = %d, OpenTok accepts at most 300 seconds", claims.ExpiresAt-claims.IssuedAt)
+	}
+
+	// The JWT is reused until it is about to expire
+	if again, err := tokbox.jwtToken(); err != nil || again != token {
+		t.Fatalf("expected the cached JWT, got %v", err)
+	}
+	tokbox.SetJWTTTL(4 * time.Minute)
+	if again, err := tokbox.jwtToken(); err != nil || again == token {
+		t.Fatalf("expected a new JWT after changing the TTL, got %v", err)
+	}
+
+	for _, ttl := range []time.Duration{0, -time.Minute, 500 * time.Millisecond, 10 * time.Minute} {
+		tokbox.SetJWTTTL(ttl)
+		if _, err = tokbox.jwtToken(); err == nil {
+			t.Fatalf("expected an error for a JWT TTL of %v", ttl)
+		}
+		// The error surfaces before any request is sent
+		if _, err = tokbox.GetArchive("a1"); err == nil {
+			t.Fatalf("expected an error for a JWT TTL of %v", ttl)
+		}
+	}
+}
+
+func TestNewSessions(t *testing.T) {
+	var inFlight, maxInFlight, created int32
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		id := atomic.AddInt32(&created, 1)
+		fmt.Fprintf(w, `[{"session_id":"session-%d"}]`, id)
+	})
+	tokbox.SetConcurrency(3)
+
+	sessions, err := tokbox.NewSessions(20, SessionOptions{MediaMode: MediaRouter}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 20 {
+		t.Fatalf("got %d sessions, want 20", len(sessions))
+	}
+	seen := map[string]bool{}
+	for _, s := range sessions {
+		if s == nil || s.Client() != tokbox || seen[s.SessionID] {
+			t.Fatalf("unexpected session %+v", s)
+		}
+		seen[s.SessionID] = true
+	}
+	if maxInFlight > 3 {
+		t.Fatalf("%d requests in flight, want at most 3", maxInFlight)
+	}
+}
+
+func TestNewSessionsAbortsOnError(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	sessions, err := tokbox.NewSessions(5, SessionOptions{}, nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if sessions != nil {
+		t.Fatalf("expected no sessions, got %v", sessions)
+	}
+}
+
+func TestSetIssuerType(t *testing.T) {
+	tokbox := New("123456", "secret")
+	if err := tokbox.SetIssuerType("user"); err == nil {
+		t.Fatal("expected an error for unknown issuer type")
+	}
+	if err := tokbox.SetIssuerType(AccountIssuer); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := tokbox.jwtToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims["ist"] != "account" {
+		t.Fatalf("ist = %v, want account", claims["ist"])
+	}
+}
+
+func TestNewSessionE2EE(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		params, _ := url.ParseQuery(string(body))
+		if params.Get("e2ee") != "true" {
+			t.Errorf("e2ee = %q, want true", params.Get("e2ee"))
+		}
+		w.Write([]byte(`[{"session_id":"s1","e2ee":true}]`))
+	})
+
+	if _, err := tokbox.NewSessions(1, SessionOptions{MediaMode: P2P, E2EE: true}, nil); err == nil {
+		t.Fatal("expected an error for a relayed E2EE session")
+	}
+	if _, err := tokbox.NewSessions(1, SessionOptions{E2EE: true}, nil); err == nil {
+		t.Fatal("expected an error for an E2EE session without an explicit media mode")
+	}
+	if _, err := tokbox.NewSessions(1, SessionOptions{MediaMode: MediaRouter, ArchiveMode: AlwaysArchive, E2EE: true}, nil); err == nil {
+		t.Fatal("expected an error for an archived E2EE session")
+	}
+
+	sessions, err := tokbox.NewSessions(1, SessionOptions{MediaMode: MediaRouter, E2EE: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sessions[0].IsE2EE() {
+		t.Fatal("expected the session to report E2EE")
+	}
+}
+
+func TestGenerateE2EEKey(t *testing.T) {
+	key, err := GenerateE2EEKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw) != e2eeKeyLength {
+		t.Fatalf("key has %d bytes, want %d", len(raw), e2eeKeyLength)
+	}
+
+	other, err := GenerateE2EEKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == key {
+		t.Fatal("expected different keys")
+	}
+}
+
+func TestTokenRoleValidation(t *testing.T) {
+	tokbox := New("123456", "secret")
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	if _, err := session.Token("admin", "", 0); err == nil {
+		t.Fatal("expected an error for an unknown role")
+	}
+	if _, err := session.Token(Moderator, "", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tokbox.SetAllowedRoles(Publisher, "admin"); err == nil {
+		t.Fatal("expected an error for an unknown role")
+	}
+	if err := tokbox.SetAllowedRoles(Publisher, Subscriber); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Token(Moderator, "", 0); err == nil {
+		t.Fatal("expected an error for a disallowed role")
+	}
+	if _, err := session.Token("", "", 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnectionDataValidator(t *testing.T) {
+	tokbox := New("123456", "secret")
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	invalid := fmt.Errorf("invalid connection data")
+	tokbox.SetConnectionDataValidator(func(data string) error {
+		if !strings.HasPrefix(data, "{") {
+			return invalid
+		}
+		return nil
+	})
+
+	if _, err := session.Token(Publisher, "user=1", 0); err != invalid {
+		t.Fatalf("expected the validator error, got %v", err)
+	}
+	if _, err := session.Token(Publisher, `{"uid":"1"}`, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tokbox.SetConnectionDataValidator(nil)
+	if _, err := session.Token(Publisher, "user=1", 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tokenData returns the signed data of a T1 token
+func tokenData(t *testing.T, token string) url.Values {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(token, "T1=="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		t.Fatalf("malformed token %s", decoded)
+	}
+	data, err := url.ParseQuery(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestTokensWithJitter(t *testing.T) {
+	session := &Session{SessionID: "s1", tokbox: New("123456", "secret")}
+
+	expirations := map[int64]bool{}
+	tokens, err := session.TokensWithJitter(200, true, Publisher, "", 3600, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		data := tokenData(t, token)
+		create, _ := strconv.ParseInt(data.Get("create_time"), 10, 64)
+		expire, _ := strconv.ParseInt(data.Get("expire_time"), 10, 64)
+		if d := expire - create; d < 3600-60 || d > 3600+60 {
+			t.Fatalf("expiration %d outside of the jitter window", d)
+		}
+		expirations[expire-create] = true
+	}
+	if len(expirations) < 2 {
+		t.Fatal("expected jittered expirations to differ")
+	}
+
+	if tokens, err = session.Tokens(10, false, Publisher, "", 3600); err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		data := tokenData(t, token)
+		create, _ := strconv.ParseInt(data.Get("create_time"), 10, 64)
+		expire, _ := strconv.ParseInt(data.Get("expire_time"), 10, 64)
+		if expire-create != 3600 {
+			t.Fatalf("expiration %d, want 3600 without jitter", expire-create)
+		}
+	}
+}
+
+func TestTokensErrors(t *testing.T) {
+	session := &Session{SessionID: "s1", tokbox: New("123456", "secret")}
+
+	for _, multithread := range []bool{false, true} {
+		tokens, err := session.Tokens(5, multithread, Role("admin"), "", 3600)
+		if err == nil || tokens != nil {
+			t.Fatalf("expected an error and no tokens, got %v, %v", tokens, err)
+		}
+		if tokens, err = session.Tokens(5, multithread, Publisher, "", 3600); err != nil || len(tokens) != 5 {
+			t.Fatalf("unexpected tokens %v, %v", tokens, err)
+		}
+	}
+}
+
+func TestMintTokensOrder(t *testing.T) {
+	for _, multithread := range []bool{false, true} {
+		tokens, err := mintTokens(50, multithread, func(i int) (string, error) {
+			// Later indexes finish first when minted concurrently
+			time.Sleep(time.Duration(50-i) * 100 * time.Microsecond)
+			return strconv.Itoa(i), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, token := range tokens {
+			if token != strconv.Itoa(i) {
+				t.Fatalf("multithread %v: token %d is %s, the order was not preserved", multithread, i, token)
+			}
+		}
+	}
+}
+
+func TestTokensWithJitterWindow(t *testing.T) {
+	session := &Session{SessionID: "s1", tokbox: New("123456", "secret")}
+
+	// Jitter must not push tokens past the 30 days OpenTok accepts
+	tokens, err := session.TokensWithJitter(200, false, Publisher, "", maxTokenTTL-10, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, token := range tokens {
+		data := tokenData(t, token)
+		create, _ := strconv.ParseInt(data.Get("create_time"), 10, 64)
+		expire, _ := strconv.ParseInt(data.Get("expire_time"), 10, 64)
+		if expire-create > maxTokenTTL {
+			t.Fatalf("token expires %d seconds after it was created", expire-create)
+		}
+	}
+}
+
+func TestTokenForUser(t *testing.T) {
+	session := &Session{SessionID: "s1", tokbox: New("123456", "secret")}
+
+	token, err := session.TokenForUser(Publisher, "user-42", 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := tokenData(t, token).Get("connection_data")
+	if data != `{"uid":"user-42"}` {
+		t.Fatalf("connection_data = %s", data)
+	}
+	if uid, ok := UIDFromConnectionData(data); !ok || uid != "user-42" {
+		t.Fatalf("uid = %q, %v", uid, ok)
+	}
+
+	if _, ok := UIDFromConnectionData("name=bob"); ok {
+		t.Fatal("expected no uid in plain connection data")
+	}
+	if _, err := session.TokenForUser(Publisher, "", 3600); err == nil {
+		t.Fatal("expected an error for an empty uid")
+	}
+}
+
+func TestBetaURLRouting(t *testing.T) {
+	var paths []string
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{"id":"a1","sessionId":"s1"}`))
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	archive, err := session.StartArchiving(true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = archive.StopArchiving(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"POST /v2/project/123456/archive", "POST /v2/project/123456/archive/a1/stop"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Fatalf("requests = %v, want %v", paths, want)
+	}
+}
+
+func TestTokenSignature(t *testing.T) {
+	const data = "session_id=s1&create_time=1700000000&role=publisher&nonce=123456"
+
+	sig, err := tokenSignature("secret", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig != "30fedc3c7f797d78c9275dc114c07ad3d5fd120e" {
+		t.Fatalf("signature = %s", sig)
+	}
+
+	token, err := encodeToken("123456", "secret", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "T1==cGFydG5lcl9pZD0xMjM0NTYmc2lnPTMwZmVkYzNjN2Y3OTdkNzhjOTI3NWRjMTE0YzA3YWQzZDVmZDEyMGU6c2Vzc2lvbl9pZD1zMSZjcmVhdGVfdGltZT0xNzAwMDAwMDAwJnJvbGU9cHVibGlzaGVyJm5vbmNlPTEyMzQ1Ng=="
+	if token != want {
+		t.Fatalf("token = %s, want %s", token, want)
+	}
+}
+
+func TestResolvedMediaMode(t *testing.T) {
+	relayed := Session{SessionID: "s1"}
+	if relayed.ResolvedMediaMode() != P2P {
+		t.Fatalf("got %s, want P2P", relayed.ResolvedMediaMode())
+	}
+
+	routed := Session{SessionID: "s1", MediaServerURL: "https://media.example.com"}
+	if routed.ResolvedMediaMode() != MediaRouter {
+		t.Fatalf("got %s, want MediaRouter", routed.ResolvedMediaMode())
+	}
+}
+
+func TestNewSessionExtraParams(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		params, _ := url.ParseQuery(string(body))
+		if params.Get("newFeature") != "on" || params.Get("archiveMode") != "manual" {
+			t.Errorf("unexpected params %v", params)
+		}
+		w.Write([]byte(`[{"session_id":"s1"}]`))
+	})
+
+	opts := SessionOptions{ArchiveMode: ManualArchive, ExtraParams: url.Values{"newFeature": {"on"}}}
+	if _, err := tokbox.NewSessions(1, opts, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mapStore is a SessionStore backed by a map
+type mapStore map[string]string
+
+func (m mapStore) Get(externalID string) (string, bool) {
+	sessionID, ok := m[externalID]
+	return sessionID, ok
+}
+
+func (m mapStore) Put(externalID, sessionID string) {
+	m[externalID] = sessionID
+}
+
+func TestGetOrCreateSession(t *testing.T) {
+	var created int32
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		id := atomic.AddInt32(&created, 1)
+		fmt.Fprintf(w, `[{"session_id":"session-%d"}]`, id)
+	})
+	store := mapStore{}
+
+	first, err := tokbox.GetOrCreateSession(nil, store, "room-1", SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := tokbox.GetOrCreateSession(nil, store, "room-1", SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := tokbox.GetOrCreateSession(nil, store, "room-2", SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created != 2 {
+		t.Fatalf("created %d sessions, want 2", created)
+	}
+	if first.SessionID != again.SessionID || first.SessionID == other.SessionID {
+		t.Fatalf("unexpected sessions %s, %s, %s", first.SessionID, again.SessionID, other.SessionID)
+	}
+	if again.Client() != tokbox || store["room-2"] != other.SessionID {
+		t.Fatal("session not bound or mapping not stored")
+	}
+}
+
+func TestParticipantCount(t *testing.T) {
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/project/123456/session/s1/stream" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"count":3,"items":[
+			{"id":"st1","videoType":"camera"},
+			{"id":"st2","videoType":"screen"},
+			{"id":"st3","videoType":"camera"}]}`))
+	})
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	count, err := session.ParticipantCount(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("count = %d, want 2", count)
+	}
+}
+
+func TestNewWithError(t *testing.T) {
+	if _, err := NewWithError("123456", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	invalid := [][2]string{{"", "secret"}, {"123456", ""}, {"my-project", "secret"}, {" 123456", "secret"}}
+	for _, c := range invalid {
+		if _, err := NewWithError(c[0], c[1]); err == nil {
+			t.Errorf("expected an error for API key %q and secret %q", c[0], c[1])
+		}
+	}
+}
+
+func TestSetNonceLength(t *testing.T) {
+	tokbox := New("123456", "secret")
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	token, err := session.Token(Publisher, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce := tokenData(t, token).Get("nonce"); len(nonce) != 2*defaultNonceLength {
+		t.Fatalf("nonce %q is not %d bytes long", nonce, defaultNonceLength)
+	}
+
+	if err = tokbox.SetNonceLength(4); err == nil {
+		t.Fatal("expected an error for a short nonce")
+	}
+	if err = tokbox.SetNonceLength(32); err != nil {
+		t.Fatal(err)
+	}
+	token, err = session.Token(Publisher, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce := tokenData(t, token).Get("nonce"); len(nonce) != 64 {
+		t.Fatalf("nonce %q is not 32 bytes long", nonce)
+	}
+}
+
+func TestSessionEqual(t *testing.T) {
+	a := &Session{SessionID: "s1", ProjectID: "123456", MediaServerURL: "https://media.example.com", tokbox: New("123456", "secret")}
+	b := &Session{SessionID: "s1", ProjectID: "123456", MediaServerURL: "https://media.example.com", tokbox: New("123456", "secret")}
+	if !a.Equal(b) {
+		t.Fatal("expected sessions with the same data to be equal")
+	}
+
+	b.SessionStatus = "closed"
+	if a.Equal(b) {
+		t.Fatal("expected sessions with different data not to be equal")
+	}
+
+	var none *Session
+	if a.Equal(none) || !none.Equal(nil) {
+		t.Fatal("unexpected nil session comparison")
+	}
+}
+
+func TestTokensNonceCollisions(t *testing.T) {
+	session := &Session{SessionID: "s1", tokbox: New("123456", "secret")}
+
+	const n = 10000
+	tokens, err := session.Tokens(n, true, Publisher, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != n {
+		t.Fatalf("got %d tokens, want %d", len(tokens), n)
+	}
+	seen := map[string]bool{}
+	for _, token := range tokens {
+		nonce := tokenData(t, token).Get("nonce")
+		if seen[nonce] {
+			t.Fatalf("nonce %s was used twice", nonce)
+		}
+		seen[nonce] = true
+	}
+}
+
+func TestSessionFromID(t *testing.T) {
+	tokbox := New("123456", "secret")
+	session := tokbox.SessionFromID("s1")
+	if session.SessionID != "s1" || session.Client() != tokbox {
+		t.Fatalf("unexpected session %+v", session)
+	}
+
+	token, err := session.Token(Publisher, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokenData(t, token).Get("session_id") != "s1" {
+		t.Fatalf("token was minted for another session")
+	}
+}
+
+func TestContextVariants(t *testing.T) {
+	release := make(chan struct{})
+	tokbox := newTestTokbox(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	defer close(release)
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := tokbox.NewSessionContext(ctx, "", MediaRouter, ManualArchive); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if _, err := session.StartArchivingContext(ctx, ArchiveOptions{HasAudio: true}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if _, err := session.StopArchivingContext(ctx, "a1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("calls took %v, the context was not bound to the requests", elapsed)
+	}
+}
@@ -0,0 +1,176 @@
+package tokbox
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+// TokenData is the data signed into a token
+type TokenData struct {
+	PartnerID      string
+	SessionID      string
+	CreateTime     time.Time
+	ExpireTime     time.Time // Zero if the token does not expire
+	Role           Role
+	ConnectionData string
+	Nonce          string
+}
+
+// ParseToken decodes a token minted with this project's credentials and
+// verifies its signature. It does not check the token times, see ValidateToken.
+func (t *Tokbox) ParseToken(token string) (*TokenData, error) {
+	if !strings.HasPrefix(token, "T1==") {
+		return nil, fmt.Errorf("unsupported token format")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(token, "T1=="))
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %v", err)
+	}
+
+	// partner_id=<api key>&sig=<signature>:<data>
+	preCoded, dataStr, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return nil, fmt.Errorf("malformed token: missing signed data")
+	}
+	header, err := url.ParseQuery(preCoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %v", err)
+	}
+	if header.Get("partner_id") != t.apiKey {
+		return nil, fmt.Errorf("token was issued for another project")
+	}
+
+	sig, err := tokenSignature(t.partnerSecret, dataStr)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(sig), []byte(header.Get("sig"))) {
+		return nil, fmt.Errorf("invalid token signature")
+	}
+
+	data, err := url.ParseQuery(dataStr)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token data: %v", err)
+	}
+	td := &TokenData{
+		PartnerID:      header.Get("partner_id"),
+		SessionID:      data.Get("session_id"),
+		Role:           Role(data.Get("role")),
+		ConnectionData: data.Get("connection_data"),
+		Nonce:          data.Get("nonce"),
+	}
+	if td.CreateTime, err = parseUnix(data.Get("create_time")); err != nil {
+		return nil, fmt.Errorf("malformed token create_time: %v", err)
+	}
+	if len(data.Get("expire_time")) > 0 {
+		if td.ExpireTime, err = parseUnix(data.Get("expire_time")); err != nil {
+			return nil, fmt.Errorf("malformed token expire_time: %v", err)
+		}
+	}
+	return td, nil
+}
+
+// ValidateToken parses a token like ParseToken and checks that it has been
+// created and has not expired yet
+func (t *Tokbox) ValidateToken(token string) (*TokenData, error) {
+	return t.ValidateTokenWithSkew(token, 0)
+}
+
+// ValidateTokenWithSkew works like ValidateToken but tolerates clocks that
+// are off by up to skew: tokens created up to skew in the future or expired
+// up to skew ago are still accepted
+func (t *Tokbox) ValidateTokenWithSkew(token string, skew time.Duration) (*TokenData, error) {
+	if skew < 0 {
+		return nil, fmt.Errorf("skew must not be negative")
+	}
+
+	td, err := t.ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if td.CreateTime.After(now.Add(skew)) {
+		return nil, fmt.Errorf("token created in the future at %v", td.CreateTime)
+	}
+	if !td.ExpireTime.IsZero() && !td.ExpireTime.After(now.Add(-skew)) {
+		return nil, fmt.Errorf("token expired at %v", td.ExpireTime)
+	}
+	return td, nil
+}
+
+// parseUnix parses a unix timestamp in seconds
+func parseUnix(s string) (time.Time, error) {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(sec, 0), nil
+}
+
+// defaultJWTTokenTTL is the lifetime of a JWT token minted without
+// expiration, in seconds. Unlike T1 tokens, JWT tokens always expire.
+const defaultJWTTokenTTL int64 = 24 * 60 * 60
+
+// tokenClaims are the claims of a JWT token
+type tokenClaims struct {
+	Ist            string `json:"ist"`
+	Scope          string `json:"scope"`
+	SessionID      string `json:"session_id"`
+	Role           Role   `json:"role,omitempty"`
+	ConnectionData string `json:"connection_data,omitempty"`
+	Nonce          string `json:"nonce"`
+	jwt.StandardClaims
+}
+
+// TokenJWT creates a token in the JWT format, signed with HS256 with the
+// project secret, which OpenTok recommends for new projects. Prefer it over
+// Token unless clients need the legacy T1 format. A token without expiration
+// expires after 24 hours.
+func (s *Session) TokenJWT(role Role, data string, expiration int64) (string, error) {
+	if err := s.tokbox.checkRole(role); err != nil {
+		return "", err
+	}
+	if err := s.tokbox.checkConnectionData(data); err != nil {
+		return "", err
+	}
+	nonce, err := s.tokbox.nonce()
+	if err != nil {
+		return "", err
+	}
+	if expiration < 0 {
+		return "", fmt.Errorf("invalid token expiration %d, it must be 0 or positive", expiration)
+	}
+	if expiration == 0 {
+		expiration = defaultJWTTokenTTL
+	}
+	now := time.Now()
+	if err = s.checkTokenExpiration(now.Add(time.Duration(expiration)*time.Second), now); err != nil {
+		return "", err
+	}
+
+	iat := now.UTC().Unix()
+	claims := tokenClaims{
+		Ist:            string(ProjectIssuer),
+		Scope:          "session.connect",
+		SessionID:      s.SessionID,
+		Role:           role,
+		ConnectionData: data,
+		Nonce:          nonce,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    s.tokbox.apiKey,
+			IssuedAt:  iat,
+			ExpiresAt: iat + expiration,
+			Id:        uuid.NewString(),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.tokbox.partnerSecret))
+}
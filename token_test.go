@@ -0,0 +1,325 @@
+package tokbox
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// signedToken mints a token with the given create and expire times
+func signedToken(t *testing.T, tokbox *Tokbox, create, expire time.Time) string {
+	data := fmt.Sprintf("session_id=s1&create_time=%d&expire_time=%d&role=publisher&nonce=1", create.Unix(), expire.Unix())
+	token, err := encodeToken(tokbox.apiKey, tokbox.partnerSecret, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestParseToken(t *testing.T) {
+	tokbox := New("123456", "secret")
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	token, err := session.Token(Moderator, "name=bob", 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	td, err := tokbox.ParseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td.SessionID != "s1" || td.Role != Moderator || td.ConnectionData != "name=bob" || td.PartnerID != "123456" {
+		t.Fatalf("unexpected token data %+v", td)
+	}
+	if td.ExpireTime.Sub(td.CreateTime) != time.Hour {
+		t.Fatalf("unexpected token lifetime %v", td.ExpireTime.Sub(td.CreateTime))
+	}
+
+	if _, err = New("123456", "other").ParseToken(token); err == nil {
+		t.Fatal("expected an error for a token signed with another secret")
+	}
+	if _, err = New("654321", "secret").ParseToken(token); err == nil {
+		t.Fatal("expected an error for a token of another project")
+	}
+}
+
+func TestValidateTokenWithSkew(t *testing.T) {
+	tokbox := New("123456", "secret")
+	now := time.Now()
+
+	expired := signedToken(t, tokbox, now.Add(-time.Hour), now.Add(-10*time.Second))
+	if _, err := tokbox.ValidateToken(expired); err == nil {
+		t.Fatal("expected an error for an expired token")
+	}
+	if _, err := tokbox.ValidateTokenWithSkew(expired, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	future := signedToken(t, tokbox, now.Add(10*time.Second), now.Add(time.Hour))
+	if _, err := tokbox.ValidateToken(future); err == nil {
+		t.Fatal("expected an error for a token created in the future")
+	}
+	if _, err := tokbox.ValidateTokenWithSkew(future, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tokbox.ValidateTokenWithSkew(expired, time.Second); err == nil {
+		t.Fatal("expected an error for a token expired beyond the skew")
+	}
+}
+
+func FuzzTokenRoundTrip(f *testing.F) {
+	f.Add(uint8(0), "", int64(0))
+	f.Add(uint8(1), "name=bob", int64(3600))
+	f.Add(uint8(2), `{"uid":"42"}`, int64(30*24*60*60))
+	f.Add(uint8(0), "a&b=c;d%20\x00\xff", int64(-1))
+
+	roles := []Role{Publisher, Subscriber, Moderator}
+	tokbox := New("123456", "secret")
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	f.Fuzz(func(t *testing.T, r uint8, connectionData string, expiration int64) {
+		role := roles[int(r)%len(roles)]
+		expiration %= 30 * 24 * 60 * 60
+
+		token, err := session.Token(role, connectionData, expiration)
+		if expiration < 0 {
+			if err == nil {
+				t.Fatal("expected an error for a negative expiration")
+			}
+			return
+		}
+		if utf8.RuneCountInString(connectionData) > maxConnectionDataLength {
+			if err == nil {
+				t.Fatal("expected an error for connection data above the limit")
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		td, err := tokbox.ParseToken(token)
+		if err != nil {
+			t.Fatalf("can not parse token %s: %v", token, err)
+		}
+		if td.SessionID != "s1" || td.Role != role || td.ConnectionData != connectionData || len(td.Nonce) == 0 {
+			t.Fatalf("unexpected token data %+v", td)
+		}
+		if expiration > 0 && td.ExpireTime.Sub(td.CreateTime) != time.Duration(expiration)*time.Second {
+			t.Fatalf("unexpected token lifetime %v", td.ExpireTime.Sub(td.CreateTime))
+		}
+		if expiration == 0 && !td.ExpireTime.IsZero() {
+			t.Fatalf("unexpected expire time %v", td.ExpireTime)
+		}
+
+		if _, err = New("123456", "other").ParseToken(token); err == nil {
+			t.Fatal("expected an error for a token signed with another secret")
+		}
+	})
+}
+
+func TestBatchTokens(t *testing.T) {
+	tokbox := New("123456", "secret")
+	tokbox.SetConcurrency(2)
+
+	specs := []SessionTokenSpec{
+		{SessionID: "s1", Role: Publisher, Expiration: 3600},
+		{SessionID: "s2", Role: Subscriber, ConnectionData: "name=bob"},
+		{SessionID: "s3", Role: Moderator, Expiration: 60},
+	}
+	tokens, err := tokbox.BatchTokens(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != len(specs) {
+		t.Fatalf("got %d tokens, want %d", len(tokens), len(specs))
+	}
+	for i, token := range tokens {
+		td, err := tokbox.ParseToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if td.SessionID != specs[i].SessionID || td.Role != specs[i].Role || td.ConnectionData != specs[i].ConnectionData {
+			t.Fatalf("token %d does not match its spec: %+v", i, td)
+		}
+	}
+
+	specs = append(specs, SessionTokenSpec{SessionID: "s4", Role: "admin"})
+	if _, err = tokbox.BatchTokens(specs); err == nil {
+		t.Fatal("expected an error for an invalid role")
+	}
+}
+
+func TestTokenJWT(t *testing.T) {
+	tokbox := New("123456", "secret")
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	token, err := session.TokenJWT(Moderator, "name=bob", 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := tokenClaims{}
+	_, err = jwt.ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			t.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return []byte("secret"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.SessionID != "s1" || claims.Role != Moderator || claims.ConnectionData != "name=bob" || claims.Issuer != "123456" || len(claims.Nonce) == 0 {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+	if claims.ExpiresAt-claims.IssuedAt != 3600 {
+		t.Fatalf("exp - iat = %d, want 3600", claims.ExpiresAt-claims.IssuedAt)
+	}
+
+	if token, err = session.TokenJWT(Publisher, "", 0); err != nil {
+		t.Fatal(err)
+	}
+	claims = tokenClaims{}
+	if _, err = jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) { return []byte("secret"), nil }); err != nil {
+		t.Fatal(err)
+	}
+	if claims.ExpiresAt-claims.IssuedAt != defaultJWTTokenTTL {
+		t.Fatalf("exp - iat = %d, want %d", claims.ExpiresAt-claims.IssuedAt, defaultJWTTokenTTL)
+	}
+
+	if _, err = session.TokenJWT("admin", "", 0); err == nil {
+		t.Fatal("expected an error for an unknown role")
+	}
+}
+
+func TestTokenWithLayoutClasses(t *testing.T) {
+	session := &Session{SessionID: "s1", tokbox: New("123456", "secret")}
+
+	token, err := session.TokenWithLayoutClasses(Publisher, "", 0, []string{"focus", "full&wide"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if classes := tokenData(t, token).Get("initial_layout_class_list"); classes != "focus full&wide" {
+		t.Fatalf("initial_layout_class_list = %q", classes)
+	}
+
+	token, err = session.TokenWithLayoutClasses(Publisher, "", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tokenData(t, token)["initial_layout_class_list"]; ok {
+		t.Fatal("initial_layout_class_list must be omitted when there are no classes")
+	}
+}
+
+func TestGenerateToken(t *testing.T) {
+	tokbox := New("123456", "secret")
+	session := &Session{SessionID: "s1", tokbox: tokbox}
+
+	expire := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	token, err := session.GenerateToken(TokenOptions{
+		Role:                   Subscriber,
+		Data:                   "name=bob",
+		ExpireTime:             expire,
+		InitialLayoutClassList: []string{"focus"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	td, err := tokbox.ParseToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td.Role != Subscriber || td.ConnectionData != "name=bob" || !td.ExpireTime.Equal(expire) {
+		t.Fatalf("unexpected token data %+v", td)
+	}
+	if tokenData(t, token).Get("initial_layout_class_list") != "focus" {
+		t.Fatal("missing initial_layout_class_list")
+	}
+
+	// A zero expire time means the token never expires
+	token, err = session.GenerateToken(TokenOptions{Role: Publisher})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td, err = tokbox.ParseToken(token); err != nil || !td.ExpireTime.IsZero() {
+		t.Fatalf("expected a token without expiration, got %+v, %v", td, err)
+	}
+
+	if _, err = session.GenerateToken(TokenOptions{Role: Publisher, ExpireTime: time.Now().Add(-time.Minute)}); err == nil {
+		t.Fatal("expected an error for an expire time in the past")
+	}
+	if _, err = session.GenerateToken(TokenOptions{Role: Publisher, ExpireTime: time.Now().Add(31 * 24 * time.Hour)}); err == nil {
+		t.Fatal("expected an error for an expire time more than 30 days away")
+	}
+	if _, err = session.Token(Publisher, "", maxTokenTTL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = session.Token(Publisher, "", maxTokenTTL+1); err == nil {
+		t.Fatal("expected an error for an expiration above 30 days")
+	}
+	if _, err = session.TokenJWT(Publisher, "", maxTokenTTL+1); err == nil {
+		t.Fatal("expected an error for a JWT token expiring after 30 days")
+	}
+	if _, err = session.Token(Publisher, "", -1); err == nil {
+		t.Fatal("expected an error for a negative expiration")
+	}
+	if _, err = session.TokenJWT(Publisher, "", -1); err == nil {
+		t.Fatal("expected an error for a negative JWT expiration")
+	}
+
+	// The 30 days window starts when the session was created, if known
+	old := &Session{SessionID: "s1", tokbox: tokbox, CreateDt: time.Now().Add(-10 * 24 * time.Hour).Format(time.UnixDate)}
+	if _, err = old.Token(Publisher, "", 25*24*60*60); err == nil {
+		t.Fatal("expected an error for a token expiring more than 30 days after the session was created")
+	}
+	if _, err = old.Token(Publisher, "", 15*24*60*60); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = old.TokenJWT(Publisher, "", 25*24*60*60); err == nil {
+		t.Fatal("expected an error for a JWT token expiring more than 30 days after the session was created")
+	}
+}
+
+func TestSessionCreatedAt(t *testing.T) {
+	tests := []struct {
+		createDt string
+		want     time.Time
+	}{
+		{"Mon Mar 17 00:41:31 PDT 2014", time.Date(2014, 3, 17, 7, 41, 31, 0, time.UTC)},
+		{"Mon Jan 13 00:41:31 EST 2014", time.Date(2014, 1, 13, 5, 41, 31, 0, time.UTC)},
+		{"Mon Mar 17 00:41:31 UTC 2014", time.Date(2014, 3, 17, 0, 41, 31, 0, time.UTC)},
+		// An unknown zone gives the earliest possible instant
+		{"Mon Mar 17 00:41:31 XYZ 2014", time.Date(2014, 3, 16, 10, 41, 31, 0, time.UTC)},
+		{"2014-03-17T00:41:31-07:00", time.Date(2014, 3, 17, 7, 41, 31, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		created, ok := (&Session{CreateDt: test.createDt}).createdAt()
+		if !ok || !created.Equal(test.want) {
+			t.Errorf("%q: got %v, %v, want %v", test.createDt, created, ok, test.want)
+		}
+	}
+	if _, ok := (&Session{CreateDt: "yesterday"}).createdAt(); ok {
+		t.Error("expected an unparsable create_dt to be unknown")
+	}
+}
+
+func TestTokenConnectionDataLength(t *testing.T) {
+	session := &Session{SessionID: "s1", tokbox: New("123456", "secret")}
+
+	// The limit counts characters before escaping
+	data := strings.Repeat("é", maxConnectionDataLength)
+	if _, err := session.Token(Publisher, data, 0); err != nil {
+		t.Fatal(err)
+	}
+	_, err := session.Token(Publisher, data+"x", 0)
+	if err == nil || !strings.Contains(err.Error(), "1000") {
+		t.Fatalf("expected an error naming the limit, got %v", err)
+	}
+	if _, err = session.TokenJWT(Publisher, data+"x", 0); err == nil {
+		t.Fatal("expected an error for a JWT token above the limit")
+	}
+}
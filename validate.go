@@ -0,0 +1,28 @@
+package tokbox
+
+import "fmt"
+
+// durationLimits are the minimum and maximum maxDuration, in seconds,
+// accepted by OpenTok for a kind of recording or stream
+type durationLimits struct {
+	min, max int
+}
+
+var (
+	archiveDurationLimits   = durationLimits{60, 172800} // 1 minute to 48 hours
+	broadcastDurationLimits = durationLimits{60, 36000}  // 1 minute to 10 hours
+	renderDurationLimits    = durationLimits{60, 36000}  // 1 minute to 10 hours
+)
+
+// validateMaxDuration checks a maxDuration option of kind against its
+// limits. 0 means the OpenTok default and is always accepted.
+func validateMaxDuration(kind string, maxDuration int, limits durationLimits) error {
+	if maxDuration == 0 {
+		return nil
+	}
+	if maxDuration < limits.min || maxDuration > limits.max {
+		return fmt.Errorf("%s maxDuration must be between %d and %d seconds, got %d",
+			kind, limits.min, limits.max, maxDuration)
+	}
+	return nil
+}
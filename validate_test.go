@@ -0,0 +1,18 @@
+package tokbox
+
+import "testing"
+
+func TestValidateMaxDuration(t *testing.T) {
+	for _, limits := range []durationLimits{archiveDurationLimits, broadcastDurationLimits, renderDurationLimits} {
+		for _, d := range []int{0, limits.min, limits.max} {
+			if err := validateMaxDuration("test", d, limits); err != nil {
+				t.Errorf("maxDuration %d: %v", d, err)
+			}
+		}
+		for _, d := range []int{-1, limits.min - 1, limits.max + 1} {
+			if err := validateMaxDuration("test", d, limits); err == nil {
+				t.Errorf("maxDuration %d: expected an error", d)
+			}
+		}
+	}
+}